@@ -0,0 +1,86 @@
+// Command fuzzygen generates standalone code from a model file.
+//
+//	go run ./cmd/fuzzygen -model mamdaniModel.json -lang go -o ./fuzzymodel
+//
+// For Go, the package `fuzzymodel.go` and its test
+// `fuzzymodel_test.go` are written to the output directory. For
+// C, `fuzzymodel.h`, `fuzzymodel.c` and the test program
+// `fuzzymodel_test.c` checking the golden vectors, which include the
+// header by the same name. For Structured Text, the function block
+// `fuzzymodel.st`.
+package main
+
+import (
+	"flag"
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	model := flag.String("model", "./mamdaniModel.json", "model file")
+	lang := flag.String("lang", "go", "language of the generated code: go, c or st")
+	out := flag.String("o", ".", "output directory")
+	name := flag.String("name", "fuzzymodel", "package name / identifier prefix / function block name")
+	resolution := flag.String("resolution", "", "comma separated resolution of the mamdani outputs")
+	samples := flag.Int("samples", 64, "number of golden vectors of the generated test")
+	float := flag.Bool("float", false, "generate C code with float instead of double by default, Structured Text with REAL instead of LREAL")
+	flag.Parse()
+
+	fc, err := fuzzy.LoadFuzzyController(*model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := fuzzy.GenOptions{Name: *name, Samples: *samples, Float: *float}
+	if *resolution != "" {
+		for _, s := range strings.Split(*resolution, ",") {
+			res, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				log.Fatalf("invalid resolution %q", s)
+			}
+			opts.Resolution = append(opts.Resolution, res)
+		}
+	}
+
+	files := map[string][]byte{}
+	switch *lang {
+	case "go":
+		src, test, err := fc.GenerateGo(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files[*name+".go"] = src
+		files[*name+"_test.go"] = test
+	case "c":
+		header, src, test, err := fc.GenerateC(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files[*name+".h"] = header
+		files[*name+".c"] = src
+		files[*name+"_test.c"] = test
+	case "st":
+		src, err := fc.GenerateST(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files[*name+".st"] = src
+	default:
+		log.Fatalf("unknown language %q", *lang)
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+	for file, content := range files {
+		path := filepath.Join(*out, file)
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("written", path)
+	}
+}
@@ -0,0 +1,73 @@
+// Command fuzzymigrate reports the model files of a directory
+// which are older than the current model version, and upgrades
+// the json ones in place with -write.
+//
+//	go run ./cmd/fuzzymigrate -dir ./models [-write]
+//
+// The exit status is 1 if any model still needs migration.
+package main
+
+import (
+	"flag"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory searched for model files")
+	write := flag.Bool("write", false, "rewrite json models of an older version")
+	flag.Parse()
+
+	outdated := 0
+	err := filepath.Walk(*dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != *dir && info.Name()[0] == '.' {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch fuzzy.FormatOf(path) {
+		case fuzzy.FormatJSON, fuzzy.FormatYAML, fuzzy.FormatTOML:
+		default:
+			return nil
+		}
+
+		version, err := fuzzy.ModelFileVersion(path)
+		if err == fuzzy.ErrNotModel {
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("%v: error: %v\n", path, err)
+			outdated++
+			return nil
+		}
+		if version >= fuzzy.ModelVersion {
+			fmt.Printf("%v: version %v, up to date\n", path, version)
+			return nil
+		}
+		if !*write {
+			fmt.Printf("%v: version %v, needs migration to %v\n", path, version, fuzzy.ModelVersion)
+			outdated++
+			return nil
+		}
+		if _, err := fuzzy.MigrateModelFile(path); err != nil {
+			fmt.Printf("%v: version %v, migration failed: %v\n", path, version, err)
+			outdated++
+			return nil
+		}
+		fmt.Printf("%v: migrated from version %v to %v\n", path, version, fuzzy.ModelVersion)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outdated > 0 {
+		os.Exit(1)
+	}
+}
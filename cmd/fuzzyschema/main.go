@@ -0,0 +1,30 @@
+// Command fuzzyschema prints the JSON Schema of the fuzzy model
+// files, for editors offering validation and autocomplete.
+//
+//	go run ./cmd/fuzzyschema -o model.schema.json
+package main
+
+import (
+	"flag"
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+func main() {
+	out := flag.String("o", "", "output file, stdout if empty")
+	flag.Parse()
+
+	schema, err := fuzzy.JSONSchema()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := ioutil.WriteFile(*out, schema, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,228 @@
+package fuzzy
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Number of rows a worker of EvaluateBatch takes at a time.
+const batchChunk = 64
+
+// Error of a single row of a batch.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %v: %v", e.Row, e.Err)
+}
+
+// Errors of the failed rows of a batch, in the order of the rows.
+// The other rows are evaluated all the same.
+type BatchError struct {
+	Rows []RowError
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Rows) == 1 {
+		return e.Rows[0].Error()
+	}
+	return fmt.Sprintf("%v rows failed, first %v", len(e.Rows), e.Rows[0])
+}
+
+// Result of a row of EvaluateStream.
+type BatchResult struct {
+	// Position of the row in the stream, from 0.
+	Index   int
+	Outputs []float64
+	Err     error
+}
+
+// Deep copy of the fuzzyController, with its own buffers, which
+// can be evaluated independently of the original one.
+func (fc *FuzzyController) Clone() (FuzzyController, error) {
+	cp := fc.copyModel()
+	if err := cp.init(); err != nil {
+		return cp, err
+	}
+	cp.SetRuleIndex(fc.RuleIndex())
+	return cp, nil
+}
+
+// Evaluating many input vectors at once, spread over GOMAXPROCS
+// goroutines, each with its own clone of the fuzzyController.
+// The fuzzyController itself is left untouched.
+//
+//	@Params: inputs - the input vectors.
+//
+//			 resolution - the resolution of every mamdani output,
+//			 as for Evaluate.
+//
+//	@Return: 1. - the outputs, in the order of the inputs. The
+//			 rows share one backing array, failed rows are nil.
+//			 2. - *BatchError if any row failed, error of the
+//			 model otherwise
+func (fc *FuzzyController) EvaluateBatch(inputs [][]float64, resolution ...int) ([][]float64, error) {
+	nOut := len(fc.Outputs)
+	outputs := make([][]float64, len(inputs))
+	errs := make([]error, len(inputs))
+	backing := make([]float64, len(inputs)*nOut)
+
+	workers := runtime.GOMAXPROCS(0)
+	if chunks := (len(inputs) + batchChunk - 1) / batchChunk; workers > chunks {
+		workers = chunks
+	}
+	clones := make([]FuzzyController, workers)
+	for w := range clones {
+		clone, err := fc.Clone()
+		if err != nil {
+			return nil, err
+		}
+		clones[w] = clone
+	}
+
+	var (
+		next int64
+		wg   sync.WaitGroup
+	)
+	for w := range clones {
+		wg.Add(1)
+		go func(clone *FuzzyController) {
+			defer wg.Done()
+			for {
+				start := int(atomic.AddInt64(&next, batchChunk)) - batchChunk
+				if start >= len(inputs) {
+					return
+				}
+				end := start + batchChunk
+				if end > len(inputs) {
+					end = len(inputs)
+				}
+				for row := start; row < end; row++ {
+					out, err := clone.Evaluate(inputs[row], resolution...)
+					if err != nil {
+						errs[row] = err
+						continue
+					}
+					outputs[row] = backing[row*nOut : (row+1)*nOut : (row+1)*nOut]
+					copy(outputs[row], out)
+				}
+			}
+		}(&clones[w])
+	}
+	wg.Wait()
+
+	var batchErr BatchError
+	for row, err := range errs {
+		if err != nil {
+			batchErr.Rows = append(batchErr.Rows, RowError{Row: row, Err: err})
+		}
+	}
+	if len(batchErr.Rows) > 0 {
+		return outputs, &batchErr
+	}
+	return outputs, nil
+}
+
+// Evaluating a stream of input vectors, spread over GOMAXPROCS
+// goroutines as EvaluateBatch. The results come in the order of
+// the inputs, each with its own error, and the result channel is
+// closed once the input channel is closed and drained, or once ctx
+// is done. At most a few rows per worker are in flight, a slow
+// reader holds the evaluation back, so the results have to be read
+// until the channel is closed or ctx is done. Once ctx is done no
+// more inputs are read and the goroutines end.
+//
+//	@Params: ctx - stopping the evaluation, e.g. when the reader
+//			 of the results is gone.
+//
+//			 inputs - the input vectors.
+//
+//			 resolution - the resolution of every mamdani output,
+//			 as for Evaluate.
+//
+//	@Return: 1. - the results
+//			 2. - error of the model, no stream is started
+func (fc *FuzzyController) EvaluateStream(ctx context.Context, inputs <-chan []float64, resolution ...int) (<-chan BatchResult, error) {
+	workers := runtime.GOMAXPROCS(0)
+	clones := make([]FuzzyController, workers)
+	for w := range clones {
+		clone, err := fc.Clone()
+		if err != nil {
+			return nil, err
+		}
+		clones[w] = clone
+	}
+
+	type job struct {
+		index  int
+		inputs []float64
+		result chan BatchResult
+	}
+	jobs := make(chan job)
+	// The pending results in the order of the inputs, bounding
+	// the rows in flight.
+	pending := make(chan chan BatchResult, 2*workers)
+	results := make(chan BatchResult)
+
+	for w := range clones {
+		go func(clone *FuzzyController) {
+			for j := range jobs {
+				out, err := clone.Evaluate(j.inputs, resolution...)
+				res := BatchResult{Index: j.index, Err: err}
+				if err == nil {
+					res.Outputs = append([]float64(nil), out...)
+				}
+				// Buffered, a cancelled stream leaves it unread.
+				j.result <- res
+			}
+		}(&clones[w])
+	}
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for index := 0; ; index++ {
+			var in []float64
+			select {
+			case row, ok := <-inputs:
+				if !ok {
+					return
+				}
+				in = row
+			case <-ctx.Done():
+				return
+			}
+			result := make(chan BatchResult, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{index: index, inputs: in, result: result}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(results)
+		for result := range pending {
+			select {
+			case res := <-result:
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
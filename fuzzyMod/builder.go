@@ -0,0 +1,303 @@
+package fuzzy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Shape of a membership function, the type and parameters of
+// a term as written in the model files.
+type Shape struct {
+	Type   string
+	Params []float64
+}
+
+// Triangular membership function, see Trimf.
+func Tri(a, b, c float64) Shape { return Shape{"trimf", []float64{a, b, c}} }
+
+// Trapezoidal membership function, see Trapmf.
+func Trap(a, b, c, d float64) Shape { return Shape{"trapmf", []float64{a, b, c, d}} }
+
+// Gaussian membership function, see Gaussmf.
+func Gauss(mean, sigma float64) Shape { return Shape{"gaussmf", []float64{mean, sigma}} }
+
+// Two combined Gaussians, see Gauss2mf.
+func Gauss2(mean1, sigma1, mean2, sigma2 float64) Shape {
+	return Shape{"gauss2mf", []float64{mean1, sigma1, mean2, sigma2}}
+}
+
+// Generalized bell membership function, see Gbellmf.
+func Bell(a, b, c float64) Shape { return Shape{"gbellmf", []float64{a, b, c}} }
+
+// Sigmoid membership function, see Sigmf.
+func Sig(center, slope float64) Shape { return Shape{"sigmf", []float64{center, slope}} }
+
+// Difference of two sigmoids, see Dsigmf.
+func Dsig(center1, slope1, center2, slope2 float64) Shape {
+	return Shape{"dsigmf", []float64{center1, slope1, center2, slope2}}
+}
+
+// Product of two sigmoids, see Psigmf.
+func Psig(center1, slope1, center2, slope2 float64) Shape {
+	return Shape{"psigmf", []float64{center1, slope1, center2, slope2}}
+}
+
+// Pi-shaped membership function, see Pimf.
+func Pi(a, b, c, d float64) Shape { return Shape{"pimf", []float64{a, b, c, d}} }
+
+// S-shaped membership function, see Smf.
+func S(a, b float64) Shape { return Shape{"smf", []float64{a, b}} }
+
+// Z-shaped membership function, see Zmf.
+func Z(a, b float64) Shape { return Shape{"zmf", []float64{a, b}} }
+
+// Constant consequent of sugeno outputs.
+func Const(value float64) Shape { return Shape{"constant", []float64{value}} }
+
+// Builder of a fuzzyController in Go, as an alternative to
+// the json model files. Every call is checked right away, the
+// first error stops the building and is returned by Build.
+//
+//	fc, err := fuzzy.NewBuilder().
+//		Input("e", -30, 30).Term("ZO", fuzzy.Tri(-10, 0, 10)).
+//		Output("u", -20, 20).Term("ZO", fuzzy.Tri(-10, 0, 10)).
+//		Rule([]string{"ZO"}, "ZO").
+//		Build()
+type Builder struct {
+	fc       FuzzyController
+	output   bool // whether Term applies to the last output or input
+	defuzzed bool // whether the defuzzification method was set
+	err      error
+}
+
+// Creating a Builder of a mamdani model, using min/max for
+// and/or, min implication, max aggregation and centroid
+// defuzzification until told otherwise.
+func NewBuilder() *Builder {
+	b := &Builder{}
+	b.fc.System = config{
+		Name:         "fuzzyModel",
+		Method:       "mamdani",
+		Andmethod:    "min",
+		Ormethod:     "max",
+		Impmethod:    "min",
+		Aggmethod:    "max",
+		Defuzzmethod: "centroid",
+	}
+	return b
+}
+
+// Name of the model.
+func (b *Builder) Name(name string) *Builder {
+	if b.err == nil {
+		b.fc.System.Name = name
+	}
+	return b
+}
+
+// Inference method, "mamdani" or "sugeno". The default
+// defuzzification follows the method unless it was set.
+func (b *Builder) Method(method string) *Builder {
+	if b.set("method", &b.fc.System.Method, method, methods) && !b.defuzzed {
+		if method == "sugeno" {
+			b.fc.System.Defuzzmethod = "wtaver"
+		} else {
+			b.fc.System.Defuzzmethod = "centroid"
+		}
+	}
+	return b
+}
+
+// Operator of `and` rules, "min" or "prod".
+func (b *Builder) And(method string) *Builder {
+	b.set("andMethod", &b.fc.System.Andmethod, method, andMethods)
+	return b
+}
+
+// Operator of `or` rules, "max", "probor" or "sum".
+func (b *Builder) Or(method string) *Builder {
+	b.set("orMethod", &b.fc.System.Ormethod, method, orMethods)
+	return b
+}
+
+// Implication method of mamdani models.
+func (b *Builder) Implication(method string) *Builder {
+	b.set("impMethod", &b.fc.System.Impmethod, method, impMethods)
+	return b
+}
+
+// Aggregation method of mamdani models.
+func (b *Builder) Aggregation(method string) *Builder {
+	b.set("aggMethod", &b.fc.System.Aggmethod, method, aggMethods)
+	return b
+}
+
+// Defuzzification method.
+func (b *Builder) Defuzzify(method string) *Builder {
+	b.defuzzed = b.set("defuzzMethod", &b.fc.System.Defuzzmethod, strings.ToLower(method), defuzzMethods)
+	return b
+}
+
+// Adding an input, the following terms belong to it.
+func (b *Builder) Input(name string, min, max float64) *Builder {
+	if b.addMember(&b.fc.Inputs, name, min, max) {
+		b.output = false
+	}
+	return b
+}
+
+// Adding an output, the following terms belong to it.
+func (b *Builder) Output(name string, min, max float64) *Builder {
+	if b.addMember(&b.fc.Outputs, name, min, max) {
+		b.output = true
+	}
+	return b
+}
+
+// Adding a labeled membership function to the last input or output.
+func (b *Builder) Term(label string, shape Shape) *Builder {
+	if b.err != nil {
+		return b
+	}
+	mbrs := b.fc.Inputs
+	if b.output {
+		mbrs = b.fc.Outputs
+	}
+	if len(mbrs) == 0 {
+		b.err = fmt.Errorf("builder: term %q added before any input or output", label)
+		return b
+	}
+	mbr := &mbrs[len(mbrs)-1]
+	if label == "" || hasLabel(*mbr, label) {
+		b.err = fmt.Errorf("builder: %q: empty or duplicated label %q", mbr.Name, label)
+		return b
+	}
+	mfType := strings.ToLower(shape.Type)
+	n, ok := mfParamCount[mfType]
+	if !ok {
+		b.err = fmt.Errorf("builder: %q: label %q has unknown type %q", mbr.Name, label, shape.Type)
+		return b
+	}
+	if len(shape.Params) != n || !paramsOrdered(mfType, shape.Params) {
+		b.err = fmt.Errorf("builder: %q: label %q has invalid parameters for %v: %v",
+			mbr.Name, label, mfType, shape.Params)
+		return b
+	}
+	params := append([]float64(nil), shape.Params...)
+	mbr.Mf = append(mbr.Mf, memberFunction{Label: label, Type: mfType, Params: params})
+	return b
+}
+
+// Adding an `and` rule. The antecedent has one label per input,
+// the consequent one label per output, both in order.
+func (b *Builder) Rule(antecedent []string, consequent ...string) *Builder {
+	b.addRule("and", antecedent, consequent)
+	return b
+}
+
+// Adding an `or` rule, see Rule.
+func (b *Builder) OrRule(antecedent []string, consequent ...string) *Builder {
+	b.addRule("or", antecedent, consequent)
+	return b
+}
+
+// Creating the fuzzyController, which is the same as reading
+// the equivalent model with NewFuzzyController.
+func (b *Builder) Build() (FuzzyController, error) {
+	if b.err != nil {
+		return FuzzyController{}, b.err
+	}
+	fc := b.fc.copyModel()
+	err := fc.init()
+	return fc, err
+}
+
+func (b *Builder) set(field string, dst *string, value string, accepted []string) bool {
+	if b.err != nil {
+		return false
+	}
+	if !contains(accepted, value) {
+		b.err = fmt.Errorf("builder: %v", enumError(field, value, accepted))
+		return false
+	}
+	*dst = value
+	return true
+}
+
+func (b *Builder) addMember(mbrs *[]member, name string, min, max float64) bool {
+	if b.err != nil {
+		return false
+	}
+	if name == "" || memberIndex(b.fc.Inputs, name) >= 0 || memberIndex(b.fc.Outputs, name) >= 0 {
+		b.err = fmt.Errorf("builder: empty or duplicated name %q", name)
+		return false
+	}
+	if !(min < max) {
+		b.err = fmt.Errorf("builder: %q: range has to be [min, max] with min < max, got [%v, %v]",
+			name, min, max)
+		return false
+	}
+	*mbrs = append(*mbrs, member{Name: name, Range: []float64{min, max}})
+	return true
+}
+
+func (b *Builder) addRule(conjunction string, antecedent []string, consequent []string) {
+	if b.err != nil {
+		return
+	}
+	n := len(b.fc.Rules)
+	if len(antecedent) != len(b.fc.Inputs) || len(consequent) != len(b.fc.Outputs) {
+		b.err = fmt.Errorf("builder: rule %v: expect %v antecedents and %v consequents, got %v and %v",
+			n, len(b.fc.Inputs), len(b.fc.Outputs), len(antecedent), len(consequent))
+		return
+	}
+	for i, label := range antecedent {
+		if !hasLabel(b.fc.Inputs[i], label) {
+			b.err = fmt.Errorf("builder: rule %v: input %q has no label %q", n, b.fc.Inputs[i].Name, label)
+			return
+		}
+	}
+	for i, label := range consequent {
+		if !hasLabel(b.fc.Outputs[i], label) {
+			b.err = fmt.Errorf("builder: rule %v: output %q has no label %q", n, b.fc.Outputs[i].Name, label)
+			return
+		}
+	}
+	b.fc.Rules = append(b.fc.Rules, rule{
+		Antecedent:  append([]string(nil), antecedent...),
+		Consequent:  append([]string(nil), consequent...),
+		Conjunction: conjunction,
+	})
+}
+
+// Deep copy of the model part of the fuzzyController, without
+// any of the derived data.
+func (fc *FuzzyController) copyModel() FuzzyController {
+	cp := FuzzyController{System: fc.System}
+	copyMembers := func(mbrs []member) []member {
+		out := make([]member, len(mbrs))
+		for i, mbr := range mbrs {
+			out[i] = member{
+				Name:  mbr.Name,
+				Range: append([]float64(nil), mbr.Range...),
+				Mf:    make([]memberFunction, len(mbr.Mf)),
+			}
+			for j, mf := range mbr.Mf {
+				mf.Params = append([]float64(nil), mf.Params...)
+				out[i].Mf[j] = mf
+			}
+		}
+		return out
+	}
+	cp.Inputs = copyMembers(fc.Inputs)
+	cp.Outputs = copyMembers(fc.Outputs)
+	cp.Rules = make([]rule, len(fc.Rules))
+	for i, r := range fc.Rules {
+		cp.Rules[i] = rule{
+			Antecedent:  append([]string(nil), r.Antecedent...),
+			Consequent:  append([]string(nil), r.Consequent...),
+			Conjunction: r.Conjunction,
+		}
+	}
+	return cp
+}
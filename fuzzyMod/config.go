@@ -0,0 +1,177 @@
+package fuzzy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the model files.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatFLL  = "fll"
+)
+
+// fuzzyController creator from a YAML document with the same
+// structure as the json model. The document goes through the
+// same strict decoding and validation as NewFuzzyController.
+func NewFuzzyControllerFromYAML(yamlStr string) (FuzzyController, error) {
+	doc, err := decodeDocument(yamlStr, FormatYAML)
+	if err != nil {
+		return FuzzyController{}, err
+	}
+	return newFromDocument(doc)
+}
+
+// fuzzyController creator from a TOML document with the same
+// structure as the json model, the inputs, outputs and rules
+// being arrays of tables (`[[input]]`, `[[input.mf]]`, ...).
+func NewFuzzyControllerFromTOML(tomlStr string) (FuzzyController, error) {
+	doc, err := decodeDocument(tomlStr, FormatTOML)
+	if err != nil {
+		return FuzzyController{}, err
+	}
+	return newFromDocument(doc)
+}
+
+// Creating a fuzzyController from a model in any of the supported
+// formats, detected from the content.
+//
+//	@Params: model - the model document in json, yaml, toml or fll.
+func ParseFuzzyController(model string) (FuzzyController, error) {
+	return NewFuzzyControllerAs(model, DetectFormat(model))
+}
+
+// Creating a fuzzyController from a model in the given format.
+//
+//	@Params: model - the model document.
+//
+//			 format - one of FormatJSON, FormatYAML, FormatTOML
+//			 or FormatFLL.
+func NewFuzzyControllerAs(model string, format string) (FuzzyController, error) {
+	switch format {
+	case FormatJSON:
+		return NewFuzzyController(model)
+	case FormatYAML:
+		return NewFuzzyControllerFromYAML(model)
+	case FormatTOML:
+		return NewFuzzyControllerFromTOML(model)
+	case FormatFLL:
+		return NewFuzzyControllerFromFLL(model)
+	}
+	return FuzzyController{}, fmt.Errorf("unknown model format %q", format)
+}
+
+// Reading a model file. The format is given by the extension
+// (.json, .yaml, .yml, .toml, .fll) and detected from the
+// content for any other file name.
+func LoadFuzzyController(path string) (FuzzyController, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return FuzzyController{}, err
+	}
+	fc, err := NewFuzzyControllerAs(string(content), fileFormat(path, content))
+	if err != nil {
+		return fc, fmt.Errorf("%v: %v", path, err)
+	}
+	return fc, nil
+}
+
+// The model format given by the extension of a file name,
+// empty if the extension is not known.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	case ".fll":
+		return FormatFLL
+	}
+	return ""
+}
+
+// The format of a model file: by its extension, detected from the
+// content only for other file names.
+func fileFormat(path string, content []byte) string {
+	if format := FormatOf(path); format != "" {
+		return format
+	}
+	return DetectFormat(string(content))
+}
+
+// A toml key/value line, `key = ...` with a bare, quoted or dotted
+// key. The value may contain anything, ':' included.
+var tomlKeyValue = regexp.MustCompile(`^("[^"]*"|'[^']*'|[A-Za-z0-9_.-]+)\s*=`)
+
+// Guessing the format of a model document from its first
+// significant line. YAML is the fallback.
+func DetectFormat(model string) string {
+	for _, line := range strings.Split(model, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "{"):
+			return FormatJSON
+		case strings.HasPrefix(line, "Engine:"):
+			return FormatFLL
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			// A toml table header, yaml flow sequences are not
+			// valid models anyway.
+			return FormatTOML
+		case tomlKeyValue.MatchString(line):
+			// Before looking for ':', which a toml value may
+			// contain, e.g. name = "a:b".
+			return FormatTOML
+		}
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// Decoding a json, yaml or toml model into a generic document,
+// which is migrated and then decoded strictly by newFromDocument.
+func decodeDocument(model string, format string) (map[string]interface{}, error) {
+	var doc interface{}
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(strings.NewReader(model))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("error by decoding the model: %v", err)
+		}
+		if dec.More() {
+			return nil, errors.New("error by decoding the model: unexpected data after the model")
+		}
+	case FormatYAML:
+		if err := yaml.Unmarshal([]byte(model), &doc); err != nil {
+			return nil, fmt.Errorf("error by decoding the yaml model: %v", err)
+		}
+	case FormatTOML:
+		var m map[string]interface{}
+		if _, err := toml.Decode(model, &m); err != nil {
+			return nil, fmt.Errorf("error by decoding the toml model: %v", err)
+		}
+		doc = m
+	default:
+		return nil, fmt.Errorf("unknown model format %q", format)
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("error by decoding the model: expect an object")
+	}
+	return m, nil
+}
@@ -24,7 +24,10 @@ func Bisector(x []float64, y []float64) (float64, error) {
 			r_area += y[r_ptr] * (x[r_ptr] - x[r_ptr-1])
 		} else {
 			l_ptr++
-			l_area += y[l_ptr] * (x[l_ptr+1] - x[l_ptr])
+			// The last point has no width to the right.
+			if l_ptr < r_ptr {
+				l_area += y[l_ptr] * (x[l_ptr+1] - x[l_ptr])
+			}
 		}
 	}
 	return x[l_ptr], nil
@@ -0,0 +1,50 @@
+// Package fuzzy evaluates mamdani and sugeno fuzzy controllers.
+//
+// # Models
+//
+// A model is read with NewFuzzyController from json, or with
+// NewFuzzyControllerAs from YAML and TOML of the same structure (see
+// test/mamdaniModel.yaml and test/sugenoModel.toml).
+// LoadFuzzyController picks the format from the file extension, or
+// from the content for other names. NewFuzzyControllerFromFLL and
+// ToFLL exchange models with fuzzylite. Models are decoded strictly:
+// unknown fields are refused, and every method, membership function
+// and rule label is checked. The counts numInputs, numOutputs and
+// numRules are optional and cross-checked when present.
+// model.schema.json is the JSON Schema of the files, regenerated by
+// `go generate ./fuzzyMod`. NewBuilder builds the same controllers
+// in Go.
+//
+// Model files carry a version, ModelVersion. Older ones are migrated
+// in memory when they are read, cmd/fuzzymigrate upgrades them on
+// disk.
+//
+// # Evaluation
+//
+// Evaluate allocates nothing in steady state: the membership
+// functions are compiled once, the output grid is built once per
+// resolution, and the results live in buffers of the controller
+// which the next evaluation overwrites. Copies share those buffers,
+// so evaluations in parallel need a Clone each, or a Handle, which
+// evaluates pooled clones of an immutable model and swaps models
+// atomically. EvaluateBatch and EvaluateStream spread many inputs
+// over GOMAXPROCS clones.
+//
+// The evaluation order is fixed: the rules in the order of the
+// model, the strengths of the rules naming the same output label
+// combined in that order (max for mamdani, sum for sugeno), and the
+// labels of an output in the order they are declared. Identical
+// inputs therefore give bit-identical outputs across runs, clones
+// and the rule index of SetRuleIndex, which computes only the rules
+// with active labels. Explain traces how the outputs came about.
+//
+// # Code generation
+//
+// GenerateGo, GenerateC and GenerateST turn a controller into a
+// standalone Go package, a C99 header/source pair without malloc, or
+// an IEC 61131-3 Structured Text function block, see cmd/fuzzygen.
+// The Go and C code come with tests checking them against the
+// interpreted controller. CompileLUT samples a controller into a
+// lookup table, evaluated in constant time by multilinear
+// interpolation.
+package fuzzy
@@ -0,0 +1,119 @@
+package fuzzy
+
+import "math"
+
+// Trace of an evaluation: how the inputs were fuzzified, how
+// strongly every rule fired and how the outputs were aggregated.
+type Explanation struct {
+	Inputs  []InputTrace  `json:"inputs"`
+	Rules   []RuleTrace   `json:"rules"`
+	Outputs []OutputTrace `json:"outputs"`
+}
+
+type InputTrace struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	// The value kept inside the range of the input, the memberships
+	// are the ones of it.
+	Clamped float64 `json:"clamped"`
+	// Membership of every label, in the order of the model.
+	Memberships []LabelDegree `json:"memberships"`
+}
+
+type RuleTrace struct {
+	Antecedent  []string `json:"antecedent"`
+	Consequent  []string `json:"consequent"`
+	Conjunction string   `json:"conjunction"`
+	// Null where undefined, as the degrees.
+	Strength *float64 `json:"strength"`
+}
+
+type OutputTrace struct {
+	Name string `json:"name"`
+	// The output value, null where it is undefined.
+	Value *float64 `json:"value"`
+	// Activation of every label named by a rule: the strengths of
+	// its rules combined, max for mamdani and sum for sugeno.
+	Activations []LabelDegree `json:"activations"`
+	// The aggregated curve of a mamdani output over its range,
+	// which is defuzzified into the value.
+	X []float64  `json:"x,omitempty"`
+	Y []*float64 `json:"y,omitempty"`
+}
+
+type LabelDegree struct {
+	Label string `json:"label"`
+	// Null where undefined, e.g. at the peak of a trimf whose peak is
+	// one of its feet.
+	Degree *float64 `json:"degree"`
+}
+
+// Evaluating the fuzzyController, as Evaluate, and tracing how the
+// outputs came about.
+//
+//	@Params: inputs - the input values.
+//
+//			 resolution - the resolution of every mamdani output,
+//			 as for Evaluate.
+//
+//	@Return: 1. - the trace, which shares nothing with the
+//			 fuzzyController
+//			 2. - error occurred during the evaluation
+func (fc *FuzzyController) Explain(inputs []float64, resolution ...int) (Explanation, error) {
+	out, err := fc.Evaluate(inputs, resolution...)
+	if err != nil {
+		return Explanation{}, err
+	}
+	strengths, err := fc.RuleStrengths(nil)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	var ex Explanation
+	for i, in := range fc.Inputs {
+		trace := InputTrace{
+			Name:    in.Name,
+			Value:   inputs[i],
+			Clamped: math.Min(math.Max(inputs[i], in.Range[0]), in.Range[1]),
+		}
+		for _, mf := range in.Mf {
+			trace.Memberships = append(trace.Memberships, LabelDegree{mf.Label, finite(fc.input_mbr[i][mf.Label])})
+		}
+		ex.Inputs = append(ex.Inputs, trace)
+	}
+	for k, r := range fc.Rules {
+		ex.Rules = append(ex.Rules, RuleTrace{
+			Antecedent:  append([]string(nil), r.Antecedent...),
+			Consequent:  append([]string(nil), r.Consequent...),
+			Conjunction: r.Conjunction,
+			Strength:    finite(strengths[k]),
+		})
+	}
+	for i, o := range fc.Outputs {
+		trace := OutputTrace{Name: o.Name, Activations: []LabelDegree{}}
+		trace.Value = finite(out[i])
+		for j, mf := range o.Mf {
+			if fc.named[i][j] {
+				trace.Activations = append(trace.Activations, LabelDegree{mf.Label, finite(fc.caps[i][j])})
+			}
+		}
+		if fc.System.Method == "mamdani" {
+			trace.X = append([]float64(nil), fc.aggX[i]...)
+			trace.Y = make([]*float64, len(fc.aggY[i]))
+			for k, y := range fc.aggY[i] {
+				trace.Y[k] = finite(y)
+			}
+		}
+		ex.Outputs = append(ex.Outputs, trace)
+	}
+	return ex, nil
+}
+
+// A copy of v, nil if it is NaN or infinite, which json can not
+// encode.
+func finite(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,547 @@
+package fuzzy
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Term names of the fuzzylite language, mapped to the membership
+// function types of this package. Parameters are reordered by
+// fllToParams/paramsToFll where the two toolkits disagree.
+var fllTerms = map[string]string{
+	"Triangle":          "trimf",
+	"Trapezoid":         "trapmf",
+	"Gaussian":          "gaussmf",
+	"GaussianProduct":   "gauss2mf",
+	"Bell":              "gbellmf",
+	"Sigmoid":           "sigmf",
+	"SigmoidDifference": "dsigmf",
+	"SigmoidProduct":    "psigmf",
+	"PiShape":           "pimf",
+	"SShape":            "smf",
+	"ZShape":            "zmf",
+	"Constant":          "constant",
+}
+
+// Norm names (conjunction, disjunction, implication and aggregation)
+// of the fuzzylite language.
+var fllNorms = map[string]string{
+	"Minimum":          "min",
+	"Maximum":          "max",
+	"AlgebraicProduct": "prod",
+	"AlgebraicSum":     "probor",
+	"UnboundedSum":     "sum",
+}
+
+// Defuzzifier names of the fuzzylite language.
+var fllDefuzzifiers = map[string]string{
+	"Centroid":          "centroid",
+	"Bisector":          "bisector",
+	"MeanOfMaximum":     "mom",
+	"SmallestOfMaximum": "som",
+	"LargestOfMaximum":  "lom",
+	"WeightedAverage":   "wtaver",
+	"WeightedSum":       "wtsum",
+}
+
+// fuzzyController creator from a fuzzylite engine. -- Public
+// method for creating a fuzzyController object out of the
+// `.fll` language used by the fuzzylite toolkits.
+//
+//	@Params: fll - the fuzzylite language string, containing
+//			 `Engine`, `InputVariable`, `OutputVariable`
+//			 and `RuleBlock` sections.
+//	@Return: fuzzyController object, initialized the same
+//			 way as NewFuzzyController does.
+func NewFuzzyControllerFromFLL(fll string) (FuzzyController, error) {
+	var (
+		fc      FuzzyController
+		current *member
+		section string
+		blocks  int
+	)
+	// Operators of the rule block, they are merged into the
+	// system config once the whole engine has been read.
+	ops := map[string]string{}
+	rules := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(fll))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			return fc, fmt.Errorf("fll line %v: expected `key: value`, got %q", n, line)
+		}
+		key, value := strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:])
+
+		switch key {
+		case "Engine":
+			fc.System.Name = value
+			section = key
+			continue
+		case "InputVariable":
+			fc.Inputs = append(fc.Inputs, member{Name: value})
+			current = &fc.Inputs[len(fc.Inputs)-1]
+			section = key
+			continue
+		case "OutputVariable":
+			fc.Outputs = append(fc.Outputs, member{Name: value})
+			current = &fc.Outputs[len(fc.Outputs)-1]
+			section = key
+			continue
+		case "RuleBlock":
+			blocks++
+			current = nil
+			section = key
+			continue
+		case "description", "enabled", "lock-range", "lock-previous", "default":
+			// Not represented by the fuzzyController, ignored.
+			continue
+		case "activation":
+			// Every rule is activated by the fuzzyController, as
+			// General does; the others would evaluate differently.
+			if value != "General" {
+				return fc, fmt.Errorf("fll line %v: error by activation %q, only General is supported", n, value)
+			}
+			continue
+		}
+
+		switch section {
+		case "InputVariable", "OutputVariable":
+			if err := parseFllVariable(&fc, current, key, value); err != nil {
+				return fc, fmt.Errorf("fll line %v: %v", n, err)
+			}
+		case "RuleBlock":
+			switch key {
+			case "conjunction", "disjunction", "implication":
+				if old, ok := ops[key]; ok && old != value {
+					return fc, fmt.Errorf(
+						"fll line %v: rule blocks with different %v (%v, %v) are not supported",
+						n, key, old, value)
+				}
+				ops[key] = value
+			case "rule":
+				rules = append(rules, value)
+			default:
+				return fc, fmt.Errorf("fll line %v: unknown rule block property %q", n, key)
+			}
+		default:
+			return fc, fmt.Errorf("fll line %v: property %q outside of a section", n, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fc, err
+	}
+	if blocks == 0 {
+		return fc, fmt.Errorf("fll engine contains no rule block")
+	}
+
+	// Operators of the rule block.
+	for key, field := range map[string]*string{
+		"conjunction": &fc.System.Andmethod,
+		"disjunction": &fc.System.Ormethod,
+		"implication": &fc.System.Impmethod,
+	} {
+		value, ok := ops[key]
+		if !ok || value == "none" {
+			continue
+		}
+		name, ok := fllNorms[value]
+		if !ok {
+			return fc, fmt.Errorf("fll %v %q is not supported", key, value)
+		}
+		*field = name
+	}
+
+	// Rules, in the positional form of the fuzzyController.
+	for _, str := range rules {
+		r, err := parseFllRule(&fc, str)
+		if err != nil {
+			return fc, fmt.Errorf("fll rule %q: %v", str, err)
+		}
+		fc.Rules = append(fc.Rules, r)
+	}
+
+	// Sugeno models are recognized by their weighted defuzzifiers.
+	if fc.System.Defuzzmethod == "wtaver" || fc.System.Defuzzmethod == "wtsum" {
+		fc.System.Method = "sugeno"
+	} else {
+		fc.System.Method = "mamdani"
+	}
+	fc.System.Numinputs = len(fc.Inputs)
+	fc.System.Numoutputs = len(fc.Outputs)
+	fc.System.Numrules = len(fc.Rules)
+
+	err := fc.init()
+	return fc, err
+}
+
+// Parsing a property of an input or output variable.
+func parseFllVariable(fc *FuzzyController, mbr *member, key string, value string) error {
+	switch key {
+	case "range":
+		nums, err := parseFllNumbers(strings.Fields(value))
+		if err != nil {
+			return err
+		}
+		if len(nums) != 2 {
+			return fmt.Errorf("range expects 2 values, got %v", len(nums))
+		}
+		mbr.Range = nums
+	case "term":
+		fields := strings.Fields(value)
+		if len(fields) < 2 {
+			return fmt.Errorf("term expects a name and a type, got %q", value)
+		}
+		mfType, ok := fllTerms[fields[1]]
+		if !ok {
+			return fmt.Errorf("term type %q is not supported", fields[1])
+		}
+		nums, err := parseFllNumbers(fields[2:])
+		if err != nil {
+			return err
+		}
+		params, err := fllToParams(mfType, nums)
+		if err != nil {
+			return fmt.Errorf("term %v: %v", fields[0], err)
+		}
+		mbr.Mf = append(mbr.Mf, memberFunction{Label: fields[0], Type: mfType, Params: params})
+	case "aggregation":
+		if value == "none" {
+			return nil
+		}
+		name, ok := fllNorms[value]
+		if !ok {
+			return fmt.Errorf("aggregation %q is not supported", value)
+		}
+		if fc.System.Aggmethod != "" && fc.System.Aggmethod != name {
+			return fmt.Errorf("outputs with different aggregations are not supported")
+		}
+		fc.System.Aggmethod = name
+	case "defuzzifier":
+		fields := strings.Fields(value)
+		if len(fields) == 0 || fields[0] == "none" {
+			return nil
+		}
+		// The resolution of fuzzylite is passed to AggregateMamdani
+		// instead, hence the trailing integer is dropped.
+		name, ok := fllDefuzzifiers[fields[0]]
+		if !ok {
+			return fmt.Errorf("defuzzifier %q is not supported", fields[0])
+		}
+		if fc.System.Defuzzmethod != "" && fc.System.Defuzzmethod != name {
+			return fmt.Errorf("outputs with different defuzzifiers are not supported")
+		}
+		fc.System.Defuzzmethod = name
+	default:
+		return fmt.Errorf("unknown variable property %q", key)
+	}
+	return nil
+}
+
+// Parsing a rule such as `if e is ZO and ec is NS then u is PS`.
+// Every input has to be mentioned once, and all the antecedents
+// have to be joined by the same conjunction.
+func parseFllRule(fc *FuzzyController, str string) (rule, error) {
+	r := rule{
+		Antecedent: make([]string, len(fc.Inputs)),
+		Consequent: make([]string, len(fc.Outputs)),
+	}
+	fields := strings.Fields(str)
+	if len(fields) < 2 || fields[0] != "if" {
+		return r, fmt.Errorf("rule has to start with `if`")
+	}
+	then := -1
+	for i, f := range fields {
+		if f == "then" {
+			then = i
+			break
+		}
+	}
+	if then < 0 {
+		return r, fmt.Errorf("rule has no `then`")
+	}
+	conseq := fields[then+1:]
+	// An optional rule weight, only the default weight is supported.
+	if n := len(conseq); n >= 2 && conseq[n-2] == "with" {
+		w, err := strconv.ParseFloat(conseq[n-1], 64)
+		if err != nil || w != 1 {
+			return r, fmt.Errorf("rule weight %q is not supported", conseq[n-1])
+		}
+		conseq = conseq[:n-2]
+	}
+
+	// Antecedent: `var is term` joined by `and`/`or`.
+	ante := fields[1:then]
+	for i := 0; i < len(ante); i += 4 {
+		if i+3 > len(ante) || ante[i+1] != "is" {
+			return r, fmt.Errorf("malformed antecedent, expected `variable is term`")
+		}
+		if i+3 < len(ante) {
+			conj := ante[i+3]
+			if conj != "and" && conj != "or" {
+				return r, fmt.Errorf("unexpected %q, hedges and parentheses are not supported", conj)
+			}
+			if r.Conjunction != "" && r.Conjunction != conj {
+				return r, fmt.Errorf("mixed `and`/`or` conjunctions are not supported")
+			}
+			r.Conjunction = conj
+		}
+		idx := memberIndex(fc.Inputs, ante[i])
+		if idx < 0 {
+			return r, fmt.Errorf("unknown input variable %q", ante[i])
+		}
+		if r.Antecedent[idx] != "" {
+			return r, fmt.Errorf("input variable %q is used twice", ante[i])
+		}
+		r.Antecedent[idx] = ante[i+2]
+	}
+	if r.Conjunction == "" {
+		r.Conjunction = "and"
+	}
+	for i, v := range r.Antecedent {
+		if v == "" {
+			return r, fmt.Errorf("input variable %q is not used", fc.Inputs[i].Name)
+		}
+	}
+
+	// Consequent: `var is term` joined by `and`.
+	for i := 0; i < len(conseq); i += 4 {
+		if i+3 > len(conseq) || conseq[i+1] != "is" {
+			return r, fmt.Errorf("malformed consequent, expected `variable is term`")
+		}
+		if i+3 < len(conseq) && conseq[i+3] != "and" {
+			return r, fmt.Errorf("unexpected %q in consequent", conseq[i+3])
+		}
+		idx := memberIndex(fc.Outputs, conseq[i])
+		if idx < 0 {
+			return r, fmt.Errorf("unknown output variable %q", conseq[i])
+		}
+		r.Consequent[idx] = conseq[i+2]
+	}
+	for i, v := range r.Consequent {
+		if v == "" {
+			return r, fmt.Errorf("output variable %q is not used", fc.Outputs[i].Name)
+		}
+	}
+	return r, nil
+}
+
+// Exporting the fuzzyController as a fuzzylite engine, which
+// can be read by NewFuzzyControllerFromFLL as well as by the
+// fuzzylite toolkits.
+//
+//	@Return: 1. - the fuzzylite language string
+//			 2. - error if the model uses anything that
+//			 fuzzylite can not express
+func (fc *FuzzyController) ToFLL() (string, error) {
+	var b strings.Builder
+
+	names := map[string]string{}
+	for k, v := range fllNorms {
+		names[v] = k
+	}
+	defuzzifiers := map[string]string{}
+	for k, v := range fllDefuzzifiers {
+		defuzzifiers[v] = k
+	}
+	terms := map[string]string{}
+	for k, v := range fllTerms {
+		terms[v] = k
+	}
+	norm := func(what string, name string) (string, error) {
+		if name == "" {
+			return "none", nil
+		}
+		if n, ok := names[name]; ok {
+			return n, nil
+		}
+		return "", fmt.Errorf("%v %q can not be exported to fll", what, name)
+	}
+
+	if err := checkFllName(fc.System.Name); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&b, "Engine: %v\n", fc.System.Name)
+
+	writeVariable := func(kind string, mbr member, output bool) error {
+		if err := checkFllName(mbr.Name); err != nil {
+			return err
+		}
+		if len(mbr.Range) != 2 {
+			return fmt.Errorf("%v %q: range expects 2 values, got %v", kind, mbr.Name, len(mbr.Range))
+		}
+		fmt.Fprintf(&b, "%v: %v\n", kind, mbr.Name)
+		fmt.Fprintf(&b, "  enabled: true\n")
+		fmt.Fprintf(&b, "  range: %v %v\n", formatFll(mbr.Range[0]), formatFll(mbr.Range[1]))
+		fmt.Fprintf(&b, "  lock-range: false\n")
+		if output {
+			agg, err := norm("aggregation", fc.System.Aggmethod)
+			if err != nil {
+				return err
+			}
+			defuzz, ok := defuzzifiers[strings.ToLower(fc.System.Defuzzmethod)]
+			if !ok {
+				return fmt.Errorf("defuzzifier %q can not be exported to fll", fc.System.Defuzzmethod)
+			}
+			fmt.Fprintf(&b, "  aggregation: %v\n", agg)
+			fmt.Fprintf(&b, "  defuzzifier: %v\n", defuzz)
+			fmt.Fprintf(&b, "  default: nan\n")
+			fmt.Fprintf(&b, "  lock-previous: false\n")
+		}
+		for _, mf := range mbr.Mf {
+			if err := checkFllName(mf.Label); err != nil {
+				return err
+			}
+			term, ok := terms[strings.ToLower(mf.Type)]
+			if !ok {
+				return fmt.Errorf("term %q: type %q can not be exported to fll", mf.Label, mf.Type)
+			}
+			params, err := paramsToFll(strings.ToLower(mf.Type), mf.Params)
+			if err != nil {
+				return fmt.Errorf("term %q: %v", mf.Label, err)
+			}
+			fmt.Fprintf(&b, "  term: %v %v", mf.Label, term)
+			for _, p := range params {
+				fmt.Fprintf(&b, " %v", formatFll(p))
+			}
+			fmt.Fprintf(&b, "\n")
+		}
+		return nil
+	}
+	for _, mbr := range fc.Inputs {
+		if err := writeVariable("InputVariable", mbr, false); err != nil {
+			return "", err
+		}
+	}
+	for _, mbr := range fc.Outputs {
+		if err := writeVariable("OutputVariable", mbr, true); err != nil {
+			return "", err
+		}
+	}
+
+	conj, err := norm("conjunction", fc.System.Andmethod)
+	if err != nil {
+		return "", err
+	}
+	disj, err := norm("disjunction", fc.System.Ormethod)
+	if err != nil {
+		return "", err
+	}
+	impl, err := norm("implication", fc.System.Impmethod)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&b, "RuleBlock: rules\n")
+	fmt.Fprintf(&b, "  enabled: true\n")
+	fmt.Fprintf(&b, "  conjunction: %v\n", conj)
+	fmt.Fprintf(&b, "  disjunction: %v\n", disj)
+	fmt.Fprintf(&b, "  implication: %v\n", impl)
+	fmt.Fprintf(&b, "  activation: General\n")
+	for i, r := range fc.Rules {
+		if len(r.Antecedent) != len(fc.Inputs) || len(r.Consequent) != len(fc.Outputs) {
+			return "", fmt.Errorf("rule %v does not match the number of inputs/outputs", i)
+		}
+		if r.Conjunction != "and" && r.Conjunction != "or" {
+			return "", fmt.Errorf("rule %v: invalid conjunction %q", i, r.Conjunction)
+		}
+		var ante, conseq []string
+		for j, label := range r.Antecedent {
+			ante = append(ante, fmt.Sprintf("%v is %v", fc.Inputs[j].Name, label))
+		}
+		for j, label := range r.Consequent {
+			conseq = append(conseq, fmt.Sprintf("%v is %v", fc.Outputs[j].Name, label))
+		}
+		fmt.Fprintf(&b, "  rule: if %v then %v\n",
+			strings.Join(ante, " "+r.Conjunction+" "),
+			strings.Join(conseq, " and "))
+	}
+	return b.String(), nil
+}
+
+// Converting fuzzylite term parameters to the parameter order
+// of this package. A trailing height of 1 is accepted.
+func fllToParams(mfType string, nums []float64) ([]float64, error) {
+	n := mfParamCount[mfType]
+	if len(nums) == n+1 && nums[n] == 1 {
+		nums = nums[:n]
+	}
+	if len(nums) != n {
+		return nil, fmt.Errorf("expects %v parameters, got %v", n, len(nums))
+	}
+	switch mfType {
+	case "gbellmf":
+		// Bell: center width slope -> gbellmf: width slope center
+		return []float64{nums[1], nums[2], nums[0]}, nil
+	case "dsigmf", "psigmf":
+		// left rising falling right -> center1 slope1 center2 slope2
+		return []float64{nums[0], nums[1], nums[3], nums[2]}, nil
+	}
+	return nums, nil
+}
+
+// Converting parameters of this package to fuzzylite term parameters.
+func paramsToFll(mfType string, params []float64) ([]float64, error) {
+	n := mfParamCount[mfType]
+	if len(params) != n {
+		return nil, fmt.Errorf("expects %v parameters, got %v", n, len(params))
+	}
+	switch mfType {
+	case "gbellmf":
+		return []float64{params[2], params[0], params[1]}, nil
+	case "dsigmf", "psigmf":
+		return []float64{params[0], params[1], params[3], params[2]}, nil
+	}
+	return params, nil
+}
+
+func parseFllNumbers(fields []string) ([]float64, error) {
+	nums := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", f)
+		}
+		nums[i] = v
+	}
+	return nums, nil
+}
+
+// Formatting the numbers without losing precision, so models can
+// round-trip through fll.
+func formatFll(v float64) string {
+	if math.IsInf(v, 1) {
+		return "inf"
+	}
+	if math.IsInf(v, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Names of fll engines, variables and terms are single words.
+func checkFllName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n#:") {
+		return fmt.Errorf("name %q can not be exported to fll", name)
+	}
+	return nil
+}
+
+func memberIndex(mbrs []member, name string) int {
+	for i, mbr := range mbrs {
+		if mbr.Name == name {
+			return i
+		}
+	}
+	return -1
+}
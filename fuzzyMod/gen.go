@@ -0,0 +1,150 @@
+package fuzzy
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Options of the code generators.
+type GenOptions struct {
+	// Name of the generated Go package, or prefix of the
+	// generated C and Structured Text identifiers.
+	Name string
+	// Resolution of every mamdani output, DefaultResolution
+	// for all of them if empty.
+	Resolution []int
+	// Number of sampled input vectors checked by the generated
+	// tests, 64 if zero.
+	Samples int
+	// Generate C code with float instead of double by default,
+	// Structured Text with REAL instead of LREAL.
+	Float bool
+}
+
+// What the code generators need to know about a model, on top
+// of the model itself.
+type genPlan struct {
+	fc *FuzzyController
+	// used[i][j] tells whether the label j of output i is a
+	// consequent of any rule, unused labels take no part in
+	// the aggregation.
+	used [][]bool
+	// antecedent[i][j] tells whether the label j of input i is
+	// an antecedent of any rule.
+	antecedent [][]bool
+	// The x grid of every mamdani output, as built by grid.
+	grids []genGrid
+}
+
+type genGrid struct {
+	start, end, step float64
+	res, count       int
+}
+
+func newGenPlan(fc *FuzzyController, opts GenOptions) (*genPlan, error) {
+	if err := fc.validate(); err != nil {
+		return nil, err
+	}
+	p := &genPlan{fc: fc, used: make([][]bool, len(fc.Outputs))}
+	for i, out := range fc.Outputs {
+		p.used[i] = make([]bool, len(out.Mf))
+		for _, r := range fc.Rules {
+			for j, mf := range out.Mf {
+				if r.Consequent[i] == mf.Label {
+					p.used[i][j] = true
+				}
+			}
+		}
+	}
+	p.antecedent = make([][]bool, len(fc.Inputs))
+	for i, in := range fc.Inputs {
+		p.antecedent[i] = make([]bool, len(in.Mf))
+		for _, r := range fc.Rules {
+			p.antecedent[i][labelIndex(in, r.Antecedent[i])] = true
+		}
+	}
+	if fc.System.Method != "mamdani" {
+		return p, nil
+	}
+
+	resolution := opts.Resolution
+	if len(resolution) == 0 {
+		resolution = make([]int, len(fc.Outputs))
+		for i := range resolution {
+			resolution[i] = DefaultResolution
+		}
+	}
+	if len(resolution) != len(fc.Outputs) {
+		return nil, fmt.Errorf("error by number of resolutions, expect %v, got %v",
+			len(fc.Outputs), len(resolution))
+	}
+	for i, out := range fc.Outputs {
+		// The same grid as the engine, so the generated loops
+		// visit exactly the same points.
+		x, err := grid(out.Range[0], out.Range[1], resolution[i])
+		if err != nil {
+			return nil, err
+		}
+		res := int(math.Min(math.Max(float64(resolution[i]), 1), 10000000))
+		g := genGrid{start: out.Range[0], end: out.Range[1], res: resolution[i], count: len(x)}
+		g.step = (g.end - g.start) / float64(res)
+		p.grids = append(p.grids, g)
+	}
+	return p, nil
+}
+
+// Whether any label of input i is used by the rules.
+func (p *genPlan) inputUsed(i int) bool {
+	for _, used := range p.antecedent[i] {
+		if used {
+			return true
+		}
+	}
+	return false
+}
+
+// The resolution the generated code was built with, for
+// running the interpreted controller the same way.
+func (p *genPlan) resolution() []int {
+	var res []int
+	for _, g := range p.grids {
+		res = append(res, g.res)
+	}
+	return res
+}
+
+// Sampling input vectors over the input ranges, together with
+// the outputs of the interpreted controller, as golden vectors
+// of the generated tests. The sampling is seeded, so the vectors
+// are the same on every generation.
+func (p *genPlan) samples(n int) ([][]float64, [][]float64, error) {
+	if n <= 0 {
+		n = 64
+	}
+	fc := p.fc.copyModel()
+	if err := fc.init(); err != nil {
+		return nil, nil, err
+	}
+	rnd := rand.New(rand.NewSource(1))
+	var ins, outs [][]float64
+	for k := 0; k < n; k++ {
+		in := make([]float64, len(fc.Inputs))
+		for i, mbr := range fc.Inputs {
+			lo, hi := mbr.Range[0], mbr.Range[1]
+			if k == 0 {
+				// Centre of the input space first.
+				in[i] = (lo + hi) / 2
+			} else {
+				in[i] = lo + rnd.Float64()*(hi-lo)
+			}
+		}
+		out, err := fc.Evaluate(in, p.resolution()...)
+		if err != nil {
+			return nil, nil, err
+		}
+		ins = append(ins, in)
+		outs = append(outs, append([]float64(nil), out...))
+	}
+	return ins, outs, nil
+}
@@ -0,0 +1,495 @@
+package fuzzy
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// C sources of the membership functions, the same calculation as
+// the functions of mf_fn.go. FZ_* are the math functions and the
+// literal suffix of the configured precision.
+var cMfFuncs = map[string]string{
+	"sigmf": `static inline REAL fz_sigmf(REAL x, REAL b, REAL c)
+{
+	return FZ_C(1.0) / (FZ_C(1.0) + FZ_EXP(-c * (x - b)));
+}`,
+	"dsigmf": `static inline REAL fz_dsigmf(REAL x, REAL b1, REAL c1, REAL b2, REAL c2)
+{
+	return fz_sigmf(x, b1, c1) - fz_sigmf(x, b2, c2);
+}`,
+	"psigmf": `static inline REAL fz_psigmf(REAL x, REAL b1, REAL c1, REAL b2, REAL c2)
+{
+	return fz_sigmf(x, b1, c1) * fz_sigmf(x, b2, c2);
+}`,
+	"gaussmf": `static inline REAL fz_gaussmf(REAL x, REAL mean, REAL sigma)
+{
+	return FZ_EXP(-(FZ_POW(x - mean, FZ_C(2.0))) / (FZ_C(2.0) * FZ_POW(sigma, FZ_C(2.0))));
+}`,
+	"gauss2mf": `static inline REAL fz_gauss2mf(REAL x, REAL mean1, REAL sigma1, REAL mean2, REAL sigma2)
+{
+	if (x <= mean1)
+		return fz_gaussmf(x, mean1, sigma1);
+	if (x > mean2)
+		return fz_gaussmf(x, mean2, sigma2);
+	return FZ_C(1.0);
+}`,
+	"gbellmf": `static inline REAL fz_gbellmf(REAL x, REAL a, REAL b, REAL c)
+{
+	return FZ_C(1.0) / (FZ_C(1.0) + FZ_POW(FZ_FABS((x - c) / a), FZ_C(2.0) * b));
+}`,
+	"pimf": `static inline REAL fz_pimf(REAL x, REAL a, REAL b, REAL c, REAL d)
+{
+	if (x <= a)
+		return FZ_C(0.0);
+	if (x > a && x <= (a + b) / FZ_C(2.0))
+		return FZ_C(2.0) * FZ_POW((x - a) / (b - a), FZ_C(2.0));
+	if (x > (a + b) / FZ_C(2.0) && x <= b)
+		return FZ_C(1.0) - FZ_C(2.0) * FZ_POW((x - b) / (b - a), FZ_C(2.0));
+	if (x >= c && x < (c + d) / FZ_C(2.0))
+		return FZ_C(1.0) - FZ_C(2.0) * FZ_POW((x - c) / (d - c), FZ_C(2.0));
+	if (x >= (c + d) / FZ_C(2.0) && x <= d)
+		return FZ_C(2.0) * FZ_POW((x - d) / (d - c), FZ_C(2.0));
+	if (x >= d)
+		return FZ_C(0.0);
+	return FZ_C(1.0);
+}`,
+	"smf": `static inline REAL fz_smf(REAL x, REAL a, REAL b)
+{
+	if (x <= a)
+		return FZ_C(0.0);
+	if (x >= a && x <= (a + b) / FZ_C(2.0))
+		return FZ_C(2.0) * FZ_POW((x - a) / (b - a), FZ_C(2.0));
+	if (x >= (a + b) / FZ_C(2.0) && x <= b)
+		return FZ_C(1.0) - FZ_C(2.0) * FZ_POW((x - b) / (b - a), FZ_C(2.0));
+	return FZ_C(1.0);
+}`,
+	"trapmf": `static inline REAL fz_trapmf(REAL x, REAL a, REAL b, REAL c, REAL d)
+{
+	if (x >= a && x < b)
+		return (x - a) / (b - a);
+	if (x >= b && x < c)
+		return FZ_C(1.0);
+	if (x >= c && x <= d)
+		return (d - x) / (d - c);
+	return FZ_C(0.0);
+}`,
+	"trimf": `static inline REAL fz_trimf(REAL x, REAL a, REAL b, REAL c)
+{
+	if (x >= a && x <= b)
+		return (x - a) / (b - a);
+	if (x > b && x <= c)
+		return (c - x) / (c - b);
+	return FZ_C(0.0);
+}`,
+	"zmf": `static inline REAL fz_zmf(REAL x, REAL a, REAL b)
+{
+	if (a <= x && x < (a + b) / FZ_C(2.0))
+		return FZ_C(1.0) - FZ_C(2.0) * FZ_POW((x - a) / (b - a), FZ_C(2.0));
+	if ((a + b) / FZ_C(2.0) <= x && x <= b)
+		return FZ_C(2.0) * FZ_POW((x - b) / (b - a), FZ_C(2.0));
+	if (x >= b)
+		return FZ_C(0.0);
+	return FZ_C(1.0);
+}`,
+}
+
+// C sources of the defuzzification methods working on the whole
+// output curve, the same calculation as defuzzy.go.
+var cDefuzzFuncs = map[string]string{
+	"bisector": `static REAL fz_bisector(const REAL *x, const REAL *y, int size)
+{
+	int l = 0, r = size - 1;
+	REAL l_area, r_area;
+	if (size == 3)
+		return x[1];
+	l_area = y[l] * (x[l + 1] - x[l]);
+	r_area = y[r] * (x[r] - x[r - 1]);
+	while (l < r) {
+		if (l_area > r_area) {
+			r--;
+			r_area += y[r] * (x[r] - x[r - 1]);
+		} else {
+			l++;
+			if (l < r)
+				l_area += y[l] * (x[l + 1] - x[l]);
+		}
+	}
+	return x[l];
+}`,
+	"som": `static REAL fz_som(const REAL *x, const REAL *y, int size)
+{
+	REAL maximum = FZ_C(0.0);
+	int i, ptr = 0;
+	for (i = 0; i < size; i++) {
+		if (y[i] > maximum) {
+			maximum = y[i];
+			ptr = i;
+		}
+	}
+	return x[ptr];
+}`,
+	"lom": `static REAL fz_lom(const REAL *x, const REAL *y, int size)
+{
+	REAL maximum = FZ_C(0.0);
+	int i, ptr = 0;
+	for (i = size - 1; i >= 0; i--) {
+		if (y[i] > maximum) {
+			maximum = y[i];
+			ptr = i;
+		}
+	}
+	return x[ptr];
+}`,
+	"mom": `static REAL fz_mom(const REAL *x, const REAL *y, int size)
+{
+	REAL l_maximum = FZ_C(0.0), r_maximum = FZ_C(0.0);
+	int i, l = 0, r = 0;
+	for (i = 0; i < size; i++) {
+		if (y[i] > l_maximum) {
+			l_maximum = y[i];
+			l = i;
+		}
+		if (y[size - 1 - i] > r_maximum) {
+			r_maximum = y[size - 1 - i];
+			r = size - 1 - i;
+		}
+	}
+	return x[(l + r) / 2];
+}`,
+}
+
+// Generating a portable C99 header/source pair of the
+// fuzzyController: `void <name>_eval(const <name>_real in[N],
+// <name>_real out[M])` with the fuzzification, the rule
+// evaluation of getCaps and the configured defuzzification, using
+// neither malloc nor any library besides <math.h>. The precision
+// is chosen by the `<NAME>_USE_FLOAT` macro, which defaults to
+// opts.Float. Mamdani defuzzifiers other than centroid keep the
+// output curves in static buffers, so those evaluations are not
+// reentrant.
+//
+//	@Params: opts - Name is the name of the files and, made a C
+//			 identifier, the prefix of the identifiers
+//			 ("fuzzymodel" if empty), Resolution the
+//			 mamdani resolution, Samples the number of golden
+//			 vectors and Float the default precision.
+//
+//	@Return: 1. - the header `<opts.Name>.h`
+//			 2. - the source `<opts.Name>.c`
+//			 3. - the test program `<opts.Name>_test.c`, checking
+//			 the golden vectors of the interpreted controller
+//			 4. - error occurred during the generation
+func (fc *FuzzyController) GenerateC(opts GenOptions) ([]byte, []byte, []byte, error) {
+	if opts.Name == "" {
+		opts.Name = "fuzzymodel"
+	}
+	p, err := newGenPlan(fc, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	name := cIdent(opts.Name)
+	upper := strings.ToUpper(name)
+	real := name + "_real"
+	nIn, nOut := len(fc.Inputs), len(fc.Outputs)
+	sys := fc.System
+	useFloat := 0
+	if opts.Float {
+		useFloat = 1
+	}
+
+	// Header.
+	var h bytes.Buffer
+	fmt.Fprintf(&h, "/* Code generated by fuzzy GenerateC from model %q. DO NOT EDIT. */\n\n", sys.Name)
+	fmt.Fprintf(&h, "#ifndef %v_H\n#define %v_H\n\n", upper, upper)
+	fmt.Fprintf(&h, "/* Define %v_USE_FLOAT as 1 for float, 0 for double. */\n", upper)
+	fmt.Fprintf(&h, "#ifndef %v_USE_FLOAT\n#define %v_USE_FLOAT %v\n#endif\n\n", upper, upper, useFloat)
+	fmt.Fprintf(&h, "#if %v_USE_FLOAT\ntypedef float %v;\n#else\ntypedef double %v;\n#endif\n\n", upper, real, real)
+	fmt.Fprintf(&h, "#define %v_NUM_INPUTS %v\n#define %v_NUM_OUTPUTS %v\n\n", upper, nIn, upper, nOut)
+	fmt.Fprintf(&h, "#ifdef __cplusplus\nextern \"C\" {\n#endif\n\n")
+	fmt.Fprintf(&h, "/*\n * Calculates the outputs of the %v model %q.\n *\n", sys.Method, sys.Name)
+	for i, mbr := range fc.Inputs {
+		fmt.Fprintf(&h, " * in[%v]  %v [%v, %v]\n", i, mbr.Name, cDouble(mbr.Range[0]), cDouble(mbr.Range[1]))
+	}
+	for i, mbr := range fc.Outputs {
+		fmt.Fprintf(&h, " * out[%v] %v [%v, %v]\n", i, mbr.Name, cDouble(mbr.Range[0]), cDouble(mbr.Range[1]))
+	}
+	fmt.Fprintf(&h, " */\nvoid %v_eval(const %v in[%v_NUM_INPUTS], %v out[%v_NUM_OUTPUTS]);\n\n",
+		name, real, upper, real, upper)
+	fmt.Fprintf(&h, "#ifdef __cplusplus\n}\n#endif\n\n#endif /* %v_H */\n", upper)
+
+	// Source.
+	var c bytes.Buffer
+	fmt.Fprintf(&c, "/* Code generated by fuzzy GenerateC from model %q. DO NOT EDIT. */\n\n", sys.Name)
+	// Included by its file name, opts.Name rather than the identifier.
+	fmt.Fprintf(&c, "#include <math.h>\n\n#include \"%v.h\"\n\n", opts.Name)
+	fmt.Fprintf(&c, "#define REAL %v\n\n", real)
+	fmt.Fprintf(&c, "#if %v_USE_FLOAT\n", upper)
+	fmt.Fprintf(&c, "#define FZ_EXP expf\n#define FZ_POW powf\n#define FZ_FABS fabsf\n#define FZ_C(v) v##f\n")
+	fmt.Fprintf(&c, "#else\n")
+	fmt.Fprintf(&c, "#define FZ_EXP exp\n#define FZ_POW pow\n#define FZ_FABS fabs\n#define FZ_C(v) v\n")
+	fmt.Fprintf(&c, "#endif\n\n")
+	fmt.Fprintf(&c, "static inline REAL fz_min(REAL a, REAL b)\n{\n\treturn a < b ? a : b;\n}\n\n")
+	fmt.Fprintf(&c, "static inline REAL fz_max(REAL a, REAL b)\n{\n\treturn a > b ? a : b;\n}\n\n")
+
+	used := map[string]bool{}
+	for i, mbr := range fc.Inputs {
+		for j, mf := range mbr.Mf {
+			if p.antecedent[i][j] {
+				used[mf.Type] = true
+			}
+		}
+	}
+	if sys.Method == "mamdani" {
+		for i, mbr := range fc.Outputs {
+			for j, mf := range mbr.Mf {
+				if p.used[i][j] {
+					used[mf.Type] = true
+				}
+			}
+		}
+	}
+	if used["dsigmf"] || used["psigmf"] {
+		used["sigmf"] = true
+	}
+	if used["gauss2mf"] {
+		used["gaussmf"] = true
+	}
+	// Dependencies first.
+	for _, t := range []string{"sigmf", "gaussmf", "dsigmf", "psigmf", "gauss2mf", "gbellmf", "pimf", "smf", "trapmf", "trimf", "zmf"} {
+		if used[t] {
+			fmt.Fprintf(&c, "%v\n\n", cMfFuncs[t])
+		}
+	}
+	defuzz := sys.Defuzzmethod
+	buffered := sys.Method == "mamdani" && defuzz != "centroid"
+	if buffered {
+		fmt.Fprintf(&c, "%v\n\n", cDefuzzFuncs[defuzz])
+		for i, g := range p.grids {
+			fmt.Fprintf(&c, "/* Curve of output %v, %v points. */\n", fc.Outputs[i].Name, g.count)
+			fmt.Fprintf(&c, "static REAL fz_xs%v[%v], fz_ys%v[%v];\n\n", i, g.count, i, g.count)
+		}
+	}
+
+	fmt.Fprintf(&c, "void %v_eval(const REAL in[%v_NUM_INPUTS], REAL out[%v_NUM_OUTPUTS])\n{\n", name, upper, upper)
+	for i, mbr := range fc.Inputs {
+		fmt.Fprintf(&c, "\t/* %v */\n", mbr.Name)
+		if !p.inputUsed(i) {
+			fmt.Fprintf(&c, "\t(void)in[%v];\n", i)
+			continue
+		}
+		fmt.Fprintf(&c, "\tconst REAL x%v = fz_min(fz_max(in[%v], %v), %v);\n",
+			i, i, cFloat(mbr.Range[0]), cFloat(mbr.Range[1]))
+		for j, mf := range mbr.Mf {
+			if p.antecedent[i][j] {
+				fmt.Fprintf(&c, "\tconst REAL m%v_%v = %v; /* %v */\n", i, j, cMfCall(mf, fmt.Sprintf("x%v", i)), mf.Label)
+			}
+		}
+	}
+
+	fmt.Fprintf(&c, "\n\t/* Rules */\n")
+	for k, r := range fc.Rules {
+		fn := sys.Andmethod
+		if r.Conjunction == "or" {
+			fn = sys.Ormethod
+		}
+		w := fmt.Sprintf("w%v", k)
+		fmt.Fprintf(&c, "\tREAL %v = %v;\n", w, cFloat(1.0-normFunc(fn)(1.0, 0.0)))
+		for i, label := range r.Antecedent {
+			fmt.Fprintf(&c, "\t%v\n", cNorm(fn, w, w, fmt.Sprintf("m%v_%v", i, labelIndex(fc.Inputs[i], label))))
+		}
+	}
+
+	fmt.Fprintf(&c, "\n\t/* Consequents */\n")
+	for i, mbr := range fc.Outputs {
+		declared := map[int]bool{}
+		for k, r := range fc.Rules {
+			j := labelIndex(mbr, r.Consequent[i])
+			cp := fmt.Sprintf("c%v_%v", i, j)
+			switch {
+			case !declared[j]:
+				fmt.Fprintf(&c, "\tREAL %v = w%v;\n", cp, k)
+				declared[j] = true
+			case sys.Method == "sugeno":
+				fmt.Fprintf(&c, "\t%v += w%v;\n", cp, k)
+			default:
+				fmt.Fprintf(&c, "\t%v = fz_max(%v, w%v);\n", cp, cp, k)
+			}
+		}
+	}
+
+	for i, mbr := range fc.Outputs {
+		fmt.Fprintf(&c, "\n\t/* %v */\n\t{\n", mbr.Name)
+		if sys.Method == "sugeno" {
+			fmt.Fprintf(&c, "\t\tREAL sum = FZ_C(0.0), den = FZ_C(0.0);\n")
+			for j, mf := range mbr.Mf {
+				if p.used[i][j] {
+					fmt.Fprintf(&c, "\t\tsum += %v * c%v_%v;\n\t\tden += c%v_%v;\n", cFloat(mf.Params[0]), i, j, i, j)
+				}
+			}
+			if defuzz == "wtsum" {
+				fmt.Fprintf(&c, "\t\t(void)den;\n\t\tout[%v] = sum;\n\t}\n", i)
+			} else {
+				fmt.Fprintf(&c, "\t\tout[%v] = sum / den;\n\t}\n", i)
+			}
+			continue
+		}
+		g := p.grids[i]
+		if !buffered {
+			fmt.Fprintf(&c, "\t\tREAL mass = FZ_C(0.0), den = FZ_C(0.0);\n")
+		}
+		// The points are computed from their index rather than
+		// accumulated as grid does, which would drift in float.
+		fmt.Fprintf(&c, "\t\tint n;\n")
+		fmt.Fprintf(&c, "\t\tfor (n = 0; n < %v; n++) {\n", g.count)
+		fmt.Fprintf(&c, "\t\t\tconst REAL x = %v + (REAL)n * %v;\n", cFloat(g.start), cFloat(g.step))
+		fmt.Fprintf(&c, "\t\t\tREAL y = FZ_C(0.0), v;\n")
+		for j, mf := range mbr.Mf {
+			if !p.used[i][j] {
+				continue
+			}
+			fmt.Fprintf(&c, "\t\t\tv = %v;\n", cMfCall(mf, "x"))
+			fmt.Fprintf(&c, "\t\t\t%v\n", cNorm(sys.Impmethod, "v", "v", fmt.Sprintf("c%v_%v", i, j)))
+			fmt.Fprintf(&c, "\t\t\t%v\n", cNorm(sys.Aggmethod, "y", "y", "v"))
+		}
+		if buffered {
+			fmt.Fprintf(&c, "\t\t\tfz_xs%v[n] = x;\n\t\t\tfz_ys%v[n] = y;\n\t\t}\n", i, i)
+			fmt.Fprintf(&c, "\t\tout[%v] = fz_%v(fz_xs%v, fz_ys%v, n);\n\t}\n", i, defuzz, i, i)
+		} else {
+			fmt.Fprintf(&c, "\t\t\tmass += x * y;\n\t\t\tden += y;\n\t\t}\n")
+			fmt.Fprintf(&c, "\t\tout[%v] = mass / den;\n\t}\n", i)
+		}
+	}
+	fmt.Fprintf(&c, "}\n")
+
+	test, err := p.cTest(name, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return h.Bytes(), c.Bytes(), test, nil
+}
+
+// The test program of the generated C code, comparing the eval
+// function with the outputs of the interpreted controller on
+// opts.Samples sampled inputs. Double precision has to match
+// closely, float within a thousandth of the output range.
+func (p *genPlan) cTest(name string, opts GenOptions) ([]byte, error) {
+	ins, outs, err := p.samples(opts.Samples)
+	if err != nil {
+		return nil, err
+	}
+	upper := strings.ToUpper(name)
+	nIn, nOut := len(p.fc.Inputs), len(p.fc.Outputs)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "/* Code generated by fuzzy GenerateC from model %q. DO NOT EDIT. */\n\n", p.fc.System.Name)
+	fmt.Fprintf(&b, "#include <math.h>\n#include <stdio.h>\n\n#include \"%v.h\"\n\n", opts.Name)
+	fmt.Fprintf(&b, "/* Outputs of the interpreted controller on sampled inputs. */\n")
+	fmt.Fprintf(&b, "static const double vectors_in[%v][%v] = {\n", len(ins), nIn)
+	for _, in := range ins {
+		fmt.Fprintf(&b, "\t{%v},\n", cDoubles(in))
+	}
+	fmt.Fprintf(&b, "};\n\nstatic const double vectors_out[%v][%v] = {\n", len(outs), nOut)
+	for _, out := range outs {
+		fmt.Fprintf(&b, "\t{%v},\n", cDoubles(out))
+	}
+	var widths []float64
+	for _, mbr := range p.fc.Outputs {
+		widths = append(widths, mbr.Range[1]-mbr.Range[0])
+	}
+	fmt.Fprintf(&b, "};\n\nstatic const double widths[%v] = {%v};\n\n", nOut, cDoubles(widths))
+	fmt.Fprintf(&b, `int main(void)
+{
+	int k, i, failed = 0;
+	for (k = 0; k < %v; k++) {
+		%v_real in[%v_NUM_INPUTS], out[%v_NUM_OUTPUTS];
+		for (i = 0; i < %v_NUM_INPUTS; i++)
+			in[i] = (%v_real)vectors_in[k][i];
+		%v_eval(in, out);
+		for (i = 0; i < %v_NUM_OUTPUTS; i++) {
+			double got = out[i], want = vectors_out[k][i];
+#if %v_USE_FLOAT
+			double tol = 1e-3 * widths[i];
+#else
+			double tol = 1e-9 * (fabs(want) > 1.0 ? fabs(want) : 1.0);
+			(void)widths;
+#endif
+			if (isnan(want) || isnan(got) ? !(isnan(want) && isnan(got)) : fabs(got - want) > tol) {
+				printf("vector %%d: out[%%d] = %%.17g, expect %%.17g\n", k, i, got, want);
+				failed++;
+			}
+		}
+	}
+	printf("%%d of %%d vectors failed\n", failed, %v);
+	return failed ? 1 : 0;
+}
+`, len(ins), name, upper, upper, upper, name, name, upper, upper, len(ins))
+	return b.Bytes(), nil
+}
+
+func cMfCall(mf memberFunction, x string) string {
+	args := make([]string, len(mf.Params))
+	for i, v := range mf.Params {
+		args[i] = cFloat(v)
+	}
+	return fmt.Sprintf("fz_%v(%v, %v)", mf.Type, x, strings.Join(args, ", "))
+}
+
+// Statement `dst = a <op> b;` of a norm, the same calculation
+// as normFunc.
+func cNorm(name string, dst string, a string, b string) string {
+	switch name {
+	case "min":
+		return fmt.Sprintf("%v = fz_min(%v, %v);", dst, a, b)
+	case "max":
+		return fmt.Sprintf("%v = fz_max(%v, %v);", dst, a, b)
+	case "prod":
+		return fmt.Sprintf("%v = %v * %v;", dst, a, b)
+	case "probor":
+		return fmt.Sprintf("%v = %v + %v - %v * %v;", dst, a, b, a, b)
+	case "sum":
+		return fmt.Sprintf("%v = %v + %v;", dst, a, b)
+	}
+	panic("unknown norm " + name)
+}
+
+// Literal of the configured precision.
+func cFloat(v float64) string {
+	return "FZ_C(" + cDouble(v) + ")"
+}
+
+// Double literal without loss of precision.
+func cDouble(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NAN"
+	case math.IsInf(v, 1):
+		return "INFINITY"
+	case math.IsInf(v, -1):
+		return "-INFINITY"
+	}
+	return goFloat(v)
+}
+
+func cDoubles(vs []float64) string {
+	strs := make([]string, len(vs))
+	for i, v := range vs {
+		strs[i] = cDouble(v)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// A C identifier out of a name.
+func cIdent(name string) string {
+	var b strings.Builder
+	for i, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r == '_', r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
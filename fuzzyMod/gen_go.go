@@ -0,0 +1,445 @@
+package fuzzy
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Go sources of the membership functions, the same calculation
+// as the functions of mf_fn.go with the parameters as arguments.
+var goMfFuncs = map[string]string{
+	"sigmf": `func sigmf(x, b, c float64) float64 {
+	return 1. / (1. + math.Exp(-c*(x-b)))
+}`,
+	"dsigmf": `func dsigmf(x, b1, c1, b2, c2 float64) float64 {
+	return sigmf(x, b1, c1) - sigmf(x, b2, c2)
+}`,
+	"psigmf": `func psigmf(x, b1, c1, b2, c2 float64) float64 {
+	return sigmf(x, b1, c1) * sigmf(x, b2, c2)
+}`,
+	"gaussmf": `func gaussmf(x, mean, sigma float64) float64 {
+	return math.Exp(-(math.Pow(x-mean, 2.0)) / (2 * math.Pow(sigma, 2.0)))
+}`,
+	"gauss2mf": `func gauss2mf(x, mean1, sigma1, mean2, sigma2 float64) float64 {
+	if x <= mean1 {
+		return gaussmf(x, mean1, sigma1)
+	}
+	if x > mean2 {
+		return gaussmf(x, mean2, sigma2)
+	}
+	return 1
+}`,
+	"gbellmf": `func gbellmf(x, a, b, c float64) float64 {
+	return 1. / (1. + math.Pow(math.Abs((x-c)/a), (2*b)))
+}`,
+	"pimf": `func pimf(x, a, b, c, d float64) float64 {
+	if x <= a {
+		return 0
+	}
+	if x > a && x <= (a+b)/2 {
+		return 2. * math.Pow((x-a)/(b-a), 2)
+	}
+	if x > (a+b)/2 && x <= b {
+		return 1 - 2.*math.Pow((x-b)/(b-a), 2)
+	}
+	if x >= c && x < (c+d)/2 {
+		return 1 - 2.*math.Pow((x-c)/(d-c), 2)
+	}
+	if x >= (c+d)/2 && x <= d {
+		return 2. * math.Pow((x-d)/(d-c), 2)
+	}
+	if x >= d {
+		return 0
+	}
+	return 1
+}`,
+	"smf": `func smf(x, a, b float64) float64 {
+	if x <= a {
+		return 0
+	}
+	if x >= a && x <= (a+b)/2 {
+		return 2. * math.Pow((x-a)/(b-a), 2)
+	}
+	if x >= (a+b)/2 && x <= b {
+		return 1 - 2*math.Pow((x-b)/(b-a), 2)
+	}
+	return 1.
+}`,
+	"trapmf": `func trapmf(x, a, b, c, d float64) float64 {
+	if x >= a && x < b {
+		return (x - a) / (b - a)
+	} else if x >= b && x < c {
+		return 1
+	} else if x >= c && x <= d {
+		return (d - x) / (d - c)
+	}
+	return 0
+}`,
+	"trimf": `func trimf(x, a, b, c float64) float64 {
+	if x >= a && x <= b {
+		return (x - a) / (b - a)
+	} else if x > b && x <= c {
+		return (c - x) / (c - b)
+	}
+	return 0.0
+}`,
+	"zmf": `func zmf(x, a, b float64) float64 {
+	if a <= x && x < (a+b)/2 {
+		return 1 - 2.*math.Pow((x-a)/(b-a), 2)
+	}
+	if (a+b)/2 <= x && x <= b {
+		return 2. * math.Pow((x-b)/(b-a), 2)
+	}
+	if x >= b {
+		return 0
+	}
+	return 1
+}`,
+}
+
+// Go sources of the defuzzification methods working on the
+// whole output curve, the same calculation as defuzzy.go.
+var goDefuzzFuncs = map[string]string{
+	"bisector": `func bisector(x []float64, y []float64) float64 {
+	if len(x) == 3 {
+		return x[1]
+	}
+	size := len(y)
+	l, r := 0, size-1
+	lArea := y[l] * (x[l+1] - x[l])
+	rArea := y[r] * (x[r] - x[r-1])
+	for l < r {
+		if lArea > rArea {
+			r--
+			rArea += y[r] * (x[r] - x[r-1])
+		} else {
+			l++
+			if l < r {
+				lArea += y[l] * (x[l+1] - x[l])
+			}
+		}
+	}
+	return x[l]
+}`,
+	"som": `func som(x []float64, y []float64) float64 {
+	maximum := 0.0
+	ptr := 0
+	for i := range x {
+		if y[i] > maximum {
+			maximum = y[i]
+			ptr = i
+		}
+	}
+	return x[ptr]
+}`,
+	"lom": `func lom(x []float64, y []float64) float64 {
+	size := len(y) - 1
+	maximum := 0.0
+	ptr := 0
+	for i := range y {
+		if y[size-i] > maximum {
+			maximum = y[size-i]
+			ptr = size - i
+		}
+	}
+	return x[ptr]
+}`,
+	"mom": `func mom(x []float64, y []float64) float64 {
+	size := len(y) - 1
+	lMaximum, rMaximum := 0.0, 0.0
+	l, r := 0, 0
+	for i := range y {
+		if y[i] > lMaximum {
+			lMaximum = y[i]
+			l = i
+		}
+		if y[size-i] > rMaximum {
+			rMaximum = y[size-i]
+			r = size - i
+		}
+	}
+	return x[(l+r)/2]
+}`,
+}
+
+// Generating standalone Go source of the fuzzyController: a
+// single `func Eval(in [N]float64) [M]float64` with the
+// membership functions, the rules and the defuzzification
+// unrolled, depending on nothing but the standard library and
+// free of heap allocations. Mamdani outputs are calculated on
+// the grid given by the resolution of the options.
+//
+//	@Params: opts - Name is the package name ("fuzzymodel" if
+//			 empty), Resolution the mamdani resolution
+//			 and Samples the number of golden vectors.
+//
+//	@Return: 1. - the source of the package
+//			 2. - the source of its test, checking Eval against
+//			 the outputs of the interpreted controller
+//			 3. - error occurred during the generation
+func (fc *FuzzyController) GenerateGo(opts GenOptions) ([]byte, []byte, error) {
+	if opts.Name == "" {
+		opts.Name = "fuzzymodel"
+	}
+	p, err := newGenPlan(fc, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	nIn, nOut := len(fc.Inputs), len(fc.Outputs)
+	sys := fc.System
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by fuzzy GenerateGo from model %q. DO NOT EDIT.\n\n", sys.Name)
+	fmt.Fprintf(&b, "package %v\n\nimport \"math\"\n\n", opts.Name)
+	fmt.Fprintf(&b, "// Number of inputs and outputs of the model.\nconst (\n\tNumInputs = %v\n\tNumOutputs = %v\n)\n\n", nIn, nOut)
+
+	fmt.Fprintf(&b, "// Eval calculates the outputs of the %v model %q.\n", sys.Method, sys.Name)
+	fmt.Fprintf(&b, "//\n// Inputs:\n")
+	for i, mbr := range fc.Inputs {
+		fmt.Fprintf(&b, "//\tin[%v]\t%v [%v, %v]\n", i, mbr.Name, goFloat(mbr.Range[0]), goFloat(mbr.Range[1]))
+	}
+	fmt.Fprintf(&b, "//\n// Outputs:\n")
+	for i, mbr := range fc.Outputs {
+		fmt.Fprintf(&b, "//\tout[%v]\t%v [%v, %v]\n", i, mbr.Name, goFloat(mbr.Range[0]), goFloat(mbr.Range[1]))
+	}
+	fmt.Fprintf(&b, "func Eval(in [%v]float64) [%v]float64 {\n\tvar out [%v]float64\n\n", nIn, nOut, nOut)
+
+	// Fuzzification.
+	used := map[string]bool{}
+	for i, mbr := range fc.Inputs {
+		fmt.Fprintf(&b, "\t// %v\n", mbr.Name)
+		if !p.inputUsed(i) {
+			fmt.Fprintf(&b, "\t_ = in[%v]\n", i)
+			continue
+		}
+		fmt.Fprintf(&b, "\tx%v := math.Min(math.Max(in[%v], %v), %v)\n",
+			i, i, goFloat(mbr.Range[0]), goFloat(mbr.Range[1]))
+		for j, mf := range mbr.Mf {
+			if !p.antecedent[i][j] {
+				continue
+			}
+			used[mf.Type] = true
+			fmt.Fprintf(&b, "\tm%v_%v := %v // %v\n", i, j, goMfCall(mf, fmt.Sprintf("x%v", i)), mf.Label)
+		}
+	}
+
+	// Rules.
+	fmt.Fprintf(&b, "\n\t// Rules\n")
+	for k, r := range fc.Rules {
+		fn := sys.Andmethod
+		if r.Conjunction == "or" {
+			fn = sys.Ormethod
+		}
+		w := fmt.Sprintf("w%v", k)
+		fmt.Fprintf(&b, "\t%v := %v\n", w, goFloat(1.0-normFunc(fn)(1.0, 0.0)))
+		for i, label := range r.Antecedent {
+			fmt.Fprintf(&b, "\t%v\n", goNorm(fn, w, w, fmt.Sprintf("m%v_%v", i, labelIndex(fc.Inputs[i], label))))
+		}
+	}
+
+	// Caps of the output labels, in the order of the rules.
+	fmt.Fprintf(&b, "\n\t// Consequents\n")
+	for i, mbr := range fc.Outputs {
+		declared := map[int]bool{}
+		for k, r := range fc.Rules {
+			j := labelIndex(mbr, r.Consequent[i])
+			c := fmt.Sprintf("c%v_%v", i, j)
+			switch {
+			case !declared[j]:
+				fmt.Fprintf(&b, "\t%v := w%v\n", c, k)
+				declared[j] = true
+			case sys.Method == "sugeno":
+				fmt.Fprintf(&b, "\t%v += w%v\n", c, k)
+			default:
+				fmt.Fprintf(&b, "\t%v = math.Max(%v, w%v)\n", c, c, k)
+			}
+		}
+	}
+
+	// Defuzzification.
+	defuzz := sys.Defuzzmethod
+	for i, mbr := range fc.Outputs {
+		fmt.Fprintf(&b, "\n\t// %v\n\t{\n", mbr.Name)
+		if sys.Method == "sugeno" {
+			fmt.Fprintf(&b, "\t\tsum, den := 0., 0.\n")
+			for j, mf := range mbr.Mf {
+				if p.used[i][j] {
+					fmt.Fprintf(&b, "\t\tsum += %v * c%v_%v\n\t\tden += c%v_%v\n", goFloat(mf.Params[0]), i, j, i, j)
+				}
+			}
+			if defuzz == "wtsum" {
+				fmt.Fprintf(&b, "\t\t_ = den\n\t\tout[%v] = sum\n\t}\n", i)
+			} else {
+				fmt.Fprintf(&b, "\t\tout[%v] = sum / den\n\t}\n", i)
+			}
+			continue
+		}
+		g := p.grids[i]
+		if defuzz == "centroid" {
+			fmt.Fprintf(&b, "\t\tmass, den := 0., 0.\n")
+		} else {
+			fmt.Fprintf(&b, "\t\tvar xs, ys [%v]float64\n", g.count)
+		}
+		fmt.Fprintf(&b, "\t\tn := 0\n")
+		fmt.Fprintf(&b, "\t\tfor x := %v; x <= %v && n < %v; x += %v {\n",
+			goFloat(g.start), goFloat(g.end), g.count, goFloat(g.step))
+		fmt.Fprintf(&b, "\t\t\ty := 0.\n")
+		for j, mf := range mbr.Mf {
+			if !p.used[i][j] {
+				continue
+			}
+			used[mf.Type] = true
+			fmt.Fprintf(&b, "\t\t\tv%v := %v\n", j, goMfCall(mf, "x"))
+			fmt.Fprintf(&b, "\t\t\t%v\n", goNorm(sys.Impmethod, fmt.Sprintf("v%v", j), fmt.Sprintf("v%v", j), fmt.Sprintf("c%v_%v", i, j)))
+			fmt.Fprintf(&b, "\t\t\t%v\n", goNorm(sys.Aggmethod, "y", "y", fmt.Sprintf("v%v", j)))
+		}
+		if defuzz == "centroid" {
+			fmt.Fprintf(&b, "\t\t\tmass += x * y\n\t\t\tden += y\n\t\t\tn++\n\t\t}\n")
+			fmt.Fprintf(&b, "\t\tout[%v] = mass / den\n\t}\n", i)
+		} else {
+			fmt.Fprintf(&b, "\t\t\txs[n], ys[n] = x, y\n\t\t\tn++\n\t\t}\n")
+			fmt.Fprintf(&b, "\t\tout[%v] = %v(xs[:n], ys[:n])\n\t}\n", i, defuzz)
+		}
+	}
+	fmt.Fprintf(&b, "\treturn out\n}\n")
+
+	// Helpers, in a stable order.
+	if used["dsigmf"] || used["psigmf"] {
+		used["sigmf"] = true
+	}
+	if used["gauss2mf"] {
+		used["gaussmf"] = true
+	}
+	var names []string
+	for name := range used {
+		if _, ok := goMfFuncs[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n%v\n", goMfFuncs[name])
+	}
+	if src, ok := goDefuzzFuncs[defuzz]; ok && sys.Method == "mamdani" {
+		fmt.Fprintf(&b, "\n%v\n", src)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error by formatting the generated source: %v", err)
+	}
+	test, err := p.goTest(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, test, nil
+}
+
+// The test of the generated package, comparing Eval with the
+// outputs of the interpreted controller on sampled inputs.
+func (p *genPlan) goTest(opts GenOptions) ([]byte, error) {
+	ins, outs, err := p.samples(opts.Samples)
+	if err != nil {
+		return nil, err
+	}
+	nIn, nOut := len(p.fc.Inputs), len(p.fc.Outputs)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by fuzzy GenerateGo from model %q. DO NOT EDIT.\n\n", p.fc.System.Name)
+	fmt.Fprintf(&b, "package %v\n\nimport (\n\t\"math\"\n\t\"testing\"\n)\n\n", opts.Name)
+	fmt.Fprintf(&b, "// Outputs of the interpreted controller on sampled inputs.\n")
+	fmt.Fprintf(&b, "var evalVectors = []struct {\n\tin [%v]float64\n\tout [%v]float64\n}{\n", nIn, nOut)
+	for k := range ins {
+		fmt.Fprintf(&b, "\t{[%v]float64{%v}, [%v]float64{%v}},\n", nIn, goFloats(ins[k]), nOut, goFloats(outs[k]))
+	}
+	fmt.Fprintf(&b, "}\n\n")
+	fmt.Fprintf(&b, `func TestEval(t *testing.T) {
+	for _, v := range evalVectors {
+		got := Eval(v.in)
+		for i := range got {
+			if !closeTo(got[i], v.out[i]) {
+				t.Errorf("Eval(%%v)[%%v] = %%v, expect %%v", v.in, i, got[i], v.out[i])
+			}
+		}
+	}
+}
+
+func TestEvalAllocs(t *testing.T) {
+	in := evalVectors[0].in
+	if n := testing.AllocsPerRun(100, func() { Eval(in) }); n != 0 {
+		t.Errorf("Eval allocates %%v times", n)
+	}
+}
+
+func closeTo(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+`)
+	return format.Source(b.Bytes())
+}
+
+// Calling the generated membership function with the parameters
+// of the model as literals.
+func goMfCall(mf memberFunction, x string) string {
+	return fmt.Sprintf("%v(%v, %v)", mf.Type, x, goFloats(mf.Params))
+}
+
+// Statement `dst = a <op> b` of a norm, the same calculation
+// as normFunc.
+func goNorm(name string, dst string, a string, b string) string {
+	switch name {
+	case "min":
+		return fmt.Sprintf("%v = math.Min(%v, %v)", dst, a, b)
+	case "max":
+		return fmt.Sprintf("%v = math.Max(%v, %v)", dst, a, b)
+	case "prod":
+		return fmt.Sprintf("%v = %v * %v", dst, a, b)
+	case "probor":
+		return fmt.Sprintf("%v = %v + %v - %v*%v", dst, a, b, a, b)
+	case "sum":
+		return fmt.Sprintf("%v = %v + %v", dst, a, b)
+	}
+	panic("unknown norm " + name)
+}
+
+// Float literal without loss of precision.
+func goFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "math.NaN()"
+	case math.IsInf(v, 1):
+		return "math.Inf(1)"
+	case math.IsInf(v, -1):
+		return "math.Inf(-1)"
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
+
+func goFloats(vs []float64) string {
+	strs := make([]string, len(vs))
+	for i, v := range vs {
+		strs[i] = goFloat(v)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func labelIndex(mbr member, label string) int {
+	for i, mf := range mbr.Mf {
+		if mf.Label == label {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,469 @@
+package fuzzy
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var stIdentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Keywords, elementary types and standard functions of IEC
+// 61131-3 which may be confused with the names of the inputs and
+// outputs.
+var stKeywords = []string{
+	"abs", "action", "and", "array", "at", "by", "case", "configuration",
+	"constant", "continue", "do", "else", "elsif", "end_action", "end_case",
+	"end_configuration", "end_for", "end_function", "end_function_block",
+	"end_if", "end_program", "end_repeat", "end_resource", "end_step",
+	"end_struct", "end_transition", "end_type", "end_var", "end_while",
+	"exit", "exp", "expt", "f_edge", "false", "for", "from", "function",
+	"function_block", "if", "initial_step", "ln", "log", "max", "min", "mod",
+	"not", "of", "on", "or", "program", "r_edge", "read_only", "read_write",
+	"repeat", "resource", "retain", "return", "sqrt", "step", "struct",
+	"task", "then", "to", "transition", "true", "type", "until", "var",
+	"var_access", "var_config", "var_external", "var_global", "var_in_out",
+	"var_input", "var_output", "var_temp", "while", "with", "xor",
+	// Elementary types.
+	"bool", "byte", "date", "date_and_time", "dint", "dt", "dword", "int",
+	"lint", "lreal", "lword", "real", "sint", "string", "time",
+	"time_of_day", "tod", "udint", "uint", "ulint", "usint", "word",
+	"wstring",
+}
+
+// Generating an IEC 61131-3 Structured Text function block of
+// the fuzzyController, for PLCs which cannot load the models.
+// The inputs and outputs are the VAR_INPUT and VAR_OUTPUT of the
+// block with the names of the model, the membership functions
+// and the rules are inlined, and mamdani outputs are calculated
+// on the fixed grid given by the resolution of the options.
+//
+//	@Params: opts - Name is the name of the function block
+//			 ("fuzzymodel" if empty), Resolution the mamdani
+//			 resolution and Float the use of REAL instead of
+//			 LREAL.
+//
+//	@Return: 1. - the source of the function block
+//			 2. - error occurred during the generation
+func (fc *FuzzyController) GenerateST(opts GenOptions) ([]byte, error) {
+	if opts.Name == "" {
+		opts.Name = "fuzzymodel"
+	}
+	p, err := newGenPlan(fc, opts)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, name := range append([]string{opts.Name}, append(memberNames(fc.Inputs), memberNames(fc.Outputs)...)...) {
+		lower := strings.ToLower(name)
+		if !stIdentPattern.MatchString(name) || strings.Contains(name, "__") ||
+			strings.HasPrefix(lower, "fz_") || contains(stKeywords, lower) {
+			return nil, fmt.Errorf("%q is not usable as identifier of Structured Text", name)
+		}
+		if names[lower] {
+			return nil, fmt.Errorf("%q is not unique in Structured Text, which ignores the case", name)
+		}
+		names[lower] = true
+	}
+	for _, mbr := range append(append([]member(nil), fc.Inputs...), fc.Outputs...) {
+		values := append([]float64(nil), mbr.Range...)
+		for _, mf := range mbr.Mf {
+			values = append(values, mf.Params...)
+		}
+		for _, v := range values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("%q: Structured Text has no literal of %v", mbr.Name, v)
+			}
+		}
+	}
+
+	real := "LREAL"
+	if opts.Float {
+		real = "REAL"
+	}
+	sys := fc.System
+	defuzz := sys.Defuzzmethod
+	buffered := sys.Method == "mamdani" && defuzz != "centroid"
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "(* Code generated by fuzzy GenerateST from model %q. DO NOT EDIT. *)\n", sys.Name)
+	fmt.Fprintf(&b, "(* Calculates the outputs of the %v model on every call. *)\n", sys.Method)
+	fmt.Fprintf(&b, "FUNCTION_BLOCK %v\n", opts.Name)
+	fmt.Fprintf(&b, "VAR_INPUT\n")
+	for _, mbr := range fc.Inputs {
+		fmt.Fprintf(&b, "\t%v : %v; (* [%v, %v] *)\n", mbr.Name, real, stFloat(mbr.Range[0]), stFloat(mbr.Range[1]))
+	}
+	fmt.Fprintf(&b, "END_VAR\nVAR_OUTPUT\n")
+	for _, mbr := range fc.Outputs {
+		fmt.Fprintf(&b, "\t%v : %v; (* [%v, %v] *)\n", mbr.Name, real, stFloat(mbr.Range[0]), stFloat(mbr.Range[1]))
+	}
+
+	// Locals.
+	fmt.Fprintf(&b, "END_VAR\nVAR\n")
+	var locals []string
+	for i := range fc.Inputs {
+		if !p.inputUsed(i) {
+			continue
+		}
+		locals = append(locals, fmt.Sprintf("fz_x%v", i))
+		for j := range fc.Inputs[i].Mf {
+			if p.antecedent[i][j] {
+				locals = append(locals, fmt.Sprintf("fz_m%v_%v", i, j))
+			}
+		}
+	}
+	for k := range fc.Rules {
+		locals = append(locals, fmt.Sprintf("fz_w%v", k))
+	}
+	for i, mbr := range fc.Outputs {
+		for j := range mbr.Mf {
+			if p.used[i][j] {
+				locals = append(locals, fmt.Sprintf("fz_c%v_%v", i, j))
+			}
+		}
+	}
+	if sys.Method == "mamdani" {
+		locals = append(locals, "fz_x", "fz_y", "fz_v")
+	}
+	if !buffered {
+		locals = append(locals, "fz_sum", "fz_den")
+	}
+	// Temporary of the membership functions.
+	locals = append(locals, "fz_t")
+	fmt.Fprintf(&b, "\t%v : %v;\n", strings.Join(locals, ", "), real)
+	if sys.Method == "mamdani" {
+		fmt.Fprintf(&b, "\tfz_n : DINT;\n")
+	}
+	if buffered {
+		fmt.Fprintf(&b, "\tfz_l, fz_r : DINT;\n\tfz_lv, fz_rv : %v;\n", real)
+		for i, g := range p.grids {
+			fmt.Fprintf(&b, "\tfz_xs%v, fz_ys%v : ARRAY[0..%v] OF %v;\n", i, i, g.count-1, real)
+		}
+	}
+	fmt.Fprintf(&b, "END_VAR\n\n")
+
+	// Fuzzification.
+	for i, mbr := range fc.Inputs {
+		if !p.inputUsed(i) {
+			fmt.Fprintf(&b, "(* %v is not used by the rules *)\n", mbr.Name)
+			continue
+		}
+		x := fmt.Sprintf("fz_x%v", i)
+		fmt.Fprintf(&b, "(* %v *)\n%v := MIN(MAX(%v, %v), %v);\n",
+			mbr.Name, x, mbr.Name, stFloat(mbr.Range[0]), stFloat(mbr.Range[1]))
+		for j, mf := range mbr.Mf {
+			if p.antecedent[i][j] {
+				fmt.Fprintf(&b, "(* %v *)\n", mf.Label)
+				b.WriteString(stMf(mf, x, fmt.Sprintf("fz_m%v_%v", i, j), ""))
+			}
+		}
+	}
+
+	// Rules.
+	fmt.Fprintf(&b, "\n(* Rules *)\n")
+	for k, r := range fc.Rules {
+		fn := sys.Andmethod
+		if r.Conjunction == "or" {
+			fn = sys.Ormethod
+		}
+		w := fmt.Sprintf("fz_w%v", k)
+		fmt.Fprintf(&b, "%v := %v;\n", w, stFloat(1.0-normFunc(fn)(1.0, 0.0)))
+		for i, label := range r.Antecedent {
+			fmt.Fprintf(&b, "%v\n", stNorm(fn, w, w, fmt.Sprintf("fz_m%v_%v", i, labelIndex(fc.Inputs[i], label))))
+		}
+	}
+
+	// Caps of the output labels, in the order of the rules.
+	fmt.Fprintf(&b, "\n(* Consequents *)\n")
+	for i, mbr := range fc.Outputs {
+		declared := map[int]bool{}
+		for k, r := range fc.Rules {
+			j := labelIndex(mbr, r.Consequent[i])
+			c := fmt.Sprintf("fz_c%v_%v", i, j)
+			switch {
+			case !declared[j]:
+				fmt.Fprintf(&b, "%v := fz_w%v;\n", c, k)
+				declared[j] = true
+			case sys.Method == "sugeno":
+				fmt.Fprintf(&b, "%v := %v + fz_w%v;\n", c, c, k)
+			default:
+				fmt.Fprintf(&b, "%v := MAX(%v, fz_w%v);\n", c, c, k)
+			}
+		}
+	}
+
+	// Defuzzification.
+	for i, mbr := range fc.Outputs {
+		fmt.Fprintf(&b, "\n(* %v *)\n", mbr.Name)
+		if sys.Method == "sugeno" {
+			fmt.Fprintf(&b, "fz_sum := 0.0;\nfz_den := 0.0;\n")
+			for j, mf := range mbr.Mf {
+				if p.used[i][j] {
+					fmt.Fprintf(&b, "fz_sum := fz_sum + %v * fz_c%v_%v;\nfz_den := fz_den + fz_c%v_%v;\n",
+						stLit(mf.Params[0]), i, j, i, j)
+				}
+			}
+			if defuzz == "wtsum" {
+				fmt.Fprintf(&b, "%v := fz_sum;\n", mbr.Name)
+			} else {
+				fmt.Fprintf(&b, "%v := fz_sum / fz_den;\n", mbr.Name)
+			}
+			continue
+		}
+		g := p.grids[i]
+		if !buffered {
+			fmt.Fprintf(&b, "fz_sum := 0.0;\nfz_den := 0.0;\n")
+		}
+		// The points are accumulated as grid does in LREAL, for the
+		// same ties of the maxima, and computed from their index
+		// in REAL, where the accumulation would drift.
+		if opts.Float {
+			fmt.Fprintf(&b, "FOR fz_n := 0 TO %v DO\n", g.count-1)
+			fmt.Fprintf(&b, "\tfz_x := %v + DINT_TO_REAL(fz_n) * %v;\n", stLit(g.start), stLit(g.step))
+		} else {
+			fmt.Fprintf(&b, "fz_x := %v;\n", stFloat(g.start))
+			fmt.Fprintf(&b, "FOR fz_n := 0 TO %v DO\n", g.count-1)
+		}
+		fmt.Fprintf(&b, "\tfz_y := 0.0;\n")
+		for j, mf := range mbr.Mf {
+			if !p.used[i][j] {
+				continue
+			}
+			b.WriteString(stMf(mf, "fz_x", "fz_v", "\t"))
+			fmt.Fprintf(&b, "\t%v\n", stNorm(sys.Impmethod, "fz_v", "fz_v", fmt.Sprintf("fz_c%v_%v", i, j)))
+			fmt.Fprintf(&b, "\t%v\n", stNorm(sys.Aggmethod, "fz_y", "fz_y", "fz_v"))
+		}
+		if buffered {
+			fmt.Fprintf(&b, "\tfz_xs%v[fz_n] := fz_x;\n\tfz_ys%v[fz_n] := fz_y;\n", i, i)
+		} else {
+			fmt.Fprintf(&b, "\tfz_sum := fz_sum + fz_x * fz_y;\n\tfz_den := fz_den + fz_y;\n")
+		}
+		if !opts.Float {
+			fmt.Fprintf(&b, "\tfz_x := fz_x + %v;\n", stLit(g.step))
+		}
+		fmt.Fprintf(&b, "END_FOR;\n")
+		if !buffered {
+			fmt.Fprintf(&b, "%v := fz_sum / fz_den;\n", mbr.Name)
+			continue
+		}
+		b.WriteString(stDefuzz(defuzz, mbr.Name, fmt.Sprintf("fz_xs%v", i), fmt.Sprintf("fz_ys%v", i), g.count))
+	}
+	fmt.Fprintf(&b, "END_FUNCTION_BLOCK\n")
+	return b.Bytes(), nil
+}
+
+// Statements `dst := mf(x);` of a membership function, the same
+// calculation as the functions of mf_fn.go with the constant
+// parts folded. Squares are products of the temporary fz_t, EXPT
+// of a negative base fails on many PLCs.
+func stMf(mf memberFunction, x string, dst string, indent string) string {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(indent)
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\n")
+	}
+	sigmoid := func(center, slope float64) string {
+		return fmt.Sprintf("(1.0 / (1.0 + EXP(%v * (%v - %v))))", stLit(-slope), x, stLit(center))
+	}
+	gauss := func(indent string, mean, sigma float64) {
+		line("%vfz_t := %v - %v;", indent, x, stLit(mean))
+		line("%v%v := EXP(-(fz_t * fz_t) / %v);", indent, dst, stLit(2*math.Pow(sigma, 2.0)))
+	}
+	// dst := <prefix>((x - center) / width)^2, in a branch.
+	square := func(prefix string, center string, width float64) {
+		line("\tfz_t := (%v - %v) / %v;", x, center, stLit(width))
+		line("\t%v := %vfz_t * fz_t;", dst, prefix)
+	}
+	p := make([]string, len(mf.Params))
+	for i, v := range mf.Params {
+		p[i] = stLit(v)
+	}
+	switch mf.Type {
+	case "trimf":
+		a, c := mf.Params[0], mf.Params[2]
+		line("IF %v >= %v AND %v <= %v THEN", x, p[0], x, p[1])
+		line("\t%v := (%v - %v) / %v;", dst, x, p[0], stLit(mf.Params[1]-a))
+		line("ELSIF %v > %v AND %v <= %v THEN", x, p[1], x, p[2])
+		line("\t%v := (%v - %v) / %v;", dst, p[2], x, stLit(c-mf.Params[1]))
+		line("ELSE")
+		line("\t%v := 0.0;", dst)
+		line("END_IF;")
+	case "trapmf":
+		a, bb, c, d := mf.Params[0], mf.Params[1], mf.Params[2], mf.Params[3]
+		line("IF %v >= %v AND %v < %v THEN", x, p[0], x, p[1])
+		line("\t%v := (%v - %v) / %v;", dst, x, p[0], stLit(bb-a))
+		line("ELSIF %v >= %v AND %v < %v THEN", x, p[1], x, p[2])
+		line("\t%v := 1.0;", dst)
+		line("ELSIF %v >= %v AND %v <= %v THEN", x, p[2], x, p[3])
+		line("\t%v := (%v - %v) / %v;", dst, p[3], x, stLit(d-c))
+		line("ELSE")
+		line("\t%v := 0.0;", dst)
+		line("END_IF;")
+	case "smf":
+		a, bb := mf.Params[0], mf.Params[1]
+		mid := stLit((a + bb) / 2)
+		line("IF %v <= %v THEN", x, p[0])
+		line("\t%v := 0.0;", dst)
+		line("ELSIF %v >= %v AND %v <= %v THEN", x, p[0], x, mid)
+		square("2.0 * ", p[0], bb-a)
+		line("ELSIF %v >= %v AND %v <= %v THEN", x, mid, x, p[1])
+		square("1.0 - 2.0 * ", p[1], bb-a)
+		line("ELSE")
+		line("\t%v := 1.0;", dst)
+		line("END_IF;")
+	case "zmf":
+		a, bb := mf.Params[0], mf.Params[1]
+		mid := stLit((a + bb) / 2)
+		line("IF %v >= %v AND %v < %v THEN", x, p[0], x, mid)
+		square("1.0 - 2.0 * ", p[0], bb-a)
+		line("ELSIF %v >= %v AND %v <= %v THEN", x, mid, x, p[1])
+		square("2.0 * ", p[1], bb-a)
+		line("ELSIF %v >= %v THEN", x, p[1])
+		line("\t%v := 0.0;", dst)
+		line("ELSE")
+		line("\t%v := 1.0;", dst)
+		line("END_IF;")
+	case "pimf":
+		a, bb, c, d := mf.Params[0], mf.Params[1], mf.Params[2], mf.Params[3]
+		mid1, mid2 := stLit((a+bb)/2), stLit((c+d)/2)
+		line("IF %v <= %v THEN", x, p[0])
+		line("\t%v := 0.0;", dst)
+		line("ELSIF %v > %v AND %v <= %v THEN", x, p[0], x, mid1)
+		square("2.0 * ", p[0], bb-a)
+		line("ELSIF %v > %v AND %v <= %v THEN", x, mid1, x, p[1])
+		square("1.0 - 2.0 * ", p[1], bb-a)
+		line("ELSIF %v >= %v AND %v < %v THEN", x, p[2], x, mid2)
+		square("1.0 - 2.0 * ", p[2], d-c)
+		line("ELSIF %v >= %v AND %v <= %v THEN", x, mid2, x, p[3])
+		square("2.0 * ", p[3], d-c)
+		line("ELSIF %v >= %v THEN", x, p[3])
+		line("\t%v := 0.0;", dst)
+		line("ELSE")
+		line("\t%v := 1.0;", dst)
+		line("END_IF;")
+	case "gaussmf":
+		gauss("", mf.Params[0], mf.Params[1])
+	case "gauss2mf":
+		line("IF %v <= %v THEN", x, p[0])
+		gauss("\t", mf.Params[0], mf.Params[1])
+		line("ELSIF %v > %v THEN", x, p[2])
+		gauss("\t", mf.Params[2], mf.Params[3])
+		line("ELSE")
+		line("\t%v := 1.0;", dst)
+		line("END_IF;")
+	case "gbellmf":
+		line("%v := 1.0 / (1.0 + EXPT(ABS((%v - %v) / %v), %v));", dst, x, p[2], p[0], stLit(2*mf.Params[1]))
+	case "sigmf":
+		line("%v := %v;", dst, sigmoid(mf.Params[0], mf.Params[1]))
+	case "dsigmf":
+		line("%v := %v - %v;", dst, sigmoid(mf.Params[0], mf.Params[1]), sigmoid(mf.Params[2], mf.Params[3]))
+	case "psigmf":
+		line("%v := %v * %v;", dst, sigmoid(mf.Params[0], mf.Params[1]), sigmoid(mf.Params[2], mf.Params[3]))
+	default:
+		panic("unknown membership function " + mf.Type)
+	}
+	return b.String()
+}
+
+// Statements of the defuzzification methods working on the whole
+// output curve, the same calculation as defuzzy.go.
+func stDefuzz(defuzz string, out string, xs string, ys string, count int) string {
+	var b strings.Builder
+	switch defuzz {
+	case "bisector":
+		if count == 3 {
+			fmt.Fprintf(&b, "%v := %v[1];\n", out, xs)
+			break
+		}
+		fmt.Fprintf(&b, "fz_l := 0;\nfz_r := %v;\n", count-1)
+		fmt.Fprintf(&b, "fz_lv := %v[fz_l] * (%v[fz_l + 1] - %v[fz_l]);\n", ys, xs, xs)
+		fmt.Fprintf(&b, "fz_rv := %v[fz_r] * (%v[fz_r] - %v[fz_r - 1]);\n", ys, xs, xs)
+		fmt.Fprintf(&b, "WHILE fz_l < fz_r DO\n")
+		fmt.Fprintf(&b, "\tIF fz_lv > fz_rv THEN\n")
+		fmt.Fprintf(&b, "\t\tfz_r := fz_r - 1;\n")
+		fmt.Fprintf(&b, "\t\tfz_rv := fz_rv + %v[fz_r] * (%v[fz_r] - %v[fz_r - 1]);\n", ys, xs, xs)
+		fmt.Fprintf(&b, "\tELSE\n")
+		fmt.Fprintf(&b, "\t\tfz_l := fz_l + 1;\n")
+		fmt.Fprintf(&b, "\t\tIF fz_l < fz_r THEN\n")
+		fmt.Fprintf(&b, "\t\t\tfz_lv := fz_lv + %v[fz_l] * (%v[fz_l + 1] - %v[fz_l]);\n", ys, xs, xs)
+		fmt.Fprintf(&b, "\t\tEND_IF;\n\tEND_IF;\nEND_WHILE;\n")
+		fmt.Fprintf(&b, "%v := %v[fz_l];\n", out, xs)
+	case "som", "lom", "mom":
+		// The first and the last index of the maximum, as the
+		// forward and backward scans of defuzzy.go.
+		fmt.Fprintf(&b, "fz_l := 0;\nfz_r := 0;\nfz_lv := 0.0;\nfz_rv := 0.0;\n")
+		fmt.Fprintf(&b, "FOR fz_n := 0 TO %v DO\n", count-1)
+		if defuzz != "lom" {
+			fmt.Fprintf(&b, "\tIF %v[fz_n] > fz_lv THEN\n\t\tfz_lv := %v[fz_n];\n\t\tfz_l := fz_n;\n\tEND_IF;\n", ys, ys)
+		}
+		if defuzz != "som" {
+			fmt.Fprintf(&b, "\tIF %v[%v - fz_n] > fz_rv THEN\n\t\tfz_rv := %v[%v - fz_n];\n\t\tfz_r := %v - fz_n;\n\tEND_IF;\n",
+				ys, count-1, ys, count-1, count-1)
+		}
+		fmt.Fprintf(&b, "END_FOR;\n")
+		switch defuzz {
+		case "som":
+			fmt.Fprintf(&b, "%v := %v[fz_l];\n", out, xs)
+		case "lom":
+			fmt.Fprintf(&b, "%v := %v[fz_r];\n", out, xs)
+		default:
+			fmt.Fprintf(&b, "%v := %v[(fz_l + fz_r) / 2];\n", out, xs)
+		}
+	default:
+		panic("unknown defuzzification method " + defuzz)
+	}
+	return b.String()
+}
+
+// Statement `dst := a <op> b;` of a norm, the same calculation
+// as normFunc.
+func stNorm(name string, dst string, a string, b string) string {
+	switch name {
+	case "min":
+		return fmt.Sprintf("%v := MIN(%v, %v);", dst, a, b)
+	case "max":
+		return fmt.Sprintf("%v := MAX(%v, %v);", dst, a, b)
+	case "prod":
+		return fmt.Sprintf("%v := %v * %v;", dst, a, b)
+	case "probor":
+		return fmt.Sprintf("%v := %v + %v - %v * %v;", dst, a, b, a, b)
+	case "sum":
+		return fmt.Sprintf("%v := %v + %v;", dst, a, b)
+	}
+	panic("unknown norm " + name)
+}
+
+// Real literal without loss of precision. The standard requires
+// the decimal point, also in front of an exponent.
+func stFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	mantissa, exponent := s, ""
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		mantissa, exponent = s[:i], "E"+s[i+1:]
+	}
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	return mantissa + exponent
+}
+
+// Real literal as operand of an expression, negative numbers in
+// parentheses.
+func stLit(v float64) string {
+	if math.Signbit(v) {
+		return "(" + stFloat(v) + ")"
+	}
+	return stFloat(v)
+}
+
+func memberNames(mbrs []member) []string {
+	names := make([]string, len(mbrs))
+	for i, mbr := range mbrs {
+		names[i] = mbr.Name
+	}
+	return names
+}
@@ -0,0 +1,205 @@
+package fuzzy
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Thread-safe handle of a fuzzyController which can be swapped
+// while evaluations run. The handle holds an immutable compiled
+// model behind an atomic pointer, every evaluation borrows a clone
+// of it from a pool, so evaluations neither lock nor wait for each
+// other. A swap only replaces the pointer: evaluations in flight
+// finish on the old model, the next ones use the new model.
+type Handle struct {
+	current atomic.Value // *Snapshot
+}
+
+// The model of a handle at the time it was taken, which a swap
+// does not change. Safe for concurrent use, several calls on one
+// snapshot always work on the same model.
+type Snapshot struct {
+	fc   FuzzyController
+	info ModelInfo
+	pool sync.Pool
+}
+
+// Names of a model and of its variables, in the order of the
+// model.
+type ModelInfo struct {
+	Name    string
+	Method  string
+	Inputs  []string
+	Outputs []string
+	Rules   int
+}
+
+func newSnapshot(fc *FuzzyController) (*Snapshot, error) {
+	cp, err := fc.Clone()
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{fc: cp}
+	s.info = ModelInfo{
+		Name:    cp.System.Name,
+		Method:  cp.System.Method,
+		Inputs:  memberNames(cp.Inputs),
+		Outputs: memberNames(cp.Outputs),
+		Rules:   len(cp.Rules),
+	}
+	s.pool.New = func() interface{} {
+		// The model is valid, cloning it cannot fail.
+		clone, _ := s.fc.Clone()
+		return &clone
+	}
+	return s, nil
+}
+
+// Handle creator.
+//
+//	@Params: fc - the fuzzyController, which is copied, later
+//			 changes of fc do not reach the handle.
+//
+//	@Return: 1. - the handle
+//			 2. - error by validating the model
+func NewHandle(fc FuzzyController) (*Handle, error) {
+	s, err := newSnapshot(&fc)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handle{}
+	h.current.Store(s)
+	return h, nil
+}
+
+// Replacing the model of the handle. The new model is copied and
+// validated first, the handle keeps the old model if it is
+// invalid. Evaluations in flight finish on the old model.
+//
+//	@Params: fc - the new fuzzyController.
+//
+//	@Return: error by validating the model
+func (h *Handle) Swap(fc FuzzyController) error {
+	s, err := newSnapshot(&fc)
+	if err != nil {
+		return err
+	}
+	h.current.Store(s)
+	return nil
+}
+
+// The current model of the handle, for several calls which have
+// to see the same model.
+func (h *Handle) Snapshot() *Snapshot {
+	return h.current.Load().(*Snapshot)
+}
+
+// Copy of the current model of the handle, which the caller is
+// free to change.
+func (h *Handle) Model() FuzzyController {
+	return h.Snapshot().Model()
+}
+
+// Evaluating the current model, as FuzzyController.Evaluate, safe
+// for concurrent use.
+//
+//	@Params: inputs - the input values.
+//
+//			 resolution - the resolution of every mamdani output.
+//
+//	@Return: 1. - the output values, owned by the caller
+//			 2. - error occurred during the evaluation
+func (h *Handle) Evaluate(inputs []float64, resolution ...int) ([]float64, error) {
+	return h.Snapshot().Evaluate(inputs, resolution...)
+}
+
+// Evaluating the current model into out, which allocates nothing
+// in steady state. Safe for concurrent use with distinct out.
+//
+//	@Params: out - the output values, one per output of the model.
+//
+//			 inputs - the input values.
+//
+//			 resolution - the resolution of every mamdani output.
+//
+//	@Return: error occurred during the evaluation
+func (h *Handle) EvaluateTo(out []float64, inputs []float64, resolution ...int) error {
+	return h.Snapshot().EvaluateTo(out, inputs, resolution...)
+}
+
+// Copy of the model of the snapshot, which the caller is free to
+// change.
+func (s *Snapshot) Model() FuzzyController {
+	cp, _ := s.fc.Clone()
+	return cp
+}
+
+// Names of the model of the snapshot. The slices are shared and
+// must not be changed.
+func (s *Snapshot) Info() ModelInfo {
+	return s.info
+}
+
+// Evaluating the model of the snapshot, as Handle.Evaluate.
+func (s *Snapshot) Evaluate(inputs []float64, resolution ...int) ([]float64, error) {
+	out := make([]float64, len(s.fc.Outputs))
+	if err := s.EvaluateTo(out, inputs, resolution...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Evaluating the model of the snapshot into out, as
+// Handle.EvaluateTo.
+func (s *Snapshot) EvaluateTo(out []float64, inputs []float64, resolution ...int) error {
+	_, err := s.EvaluateFired(out, inputs, resolution...)
+	return err
+}
+
+// Evaluating the model of the snapshot into out, as EvaluateTo.
+//
+//	@Return: 1. - the number of rules which fired, see
+//			 FuzzyController.Fired
+//			 2. - error occurred during the evaluation
+func (s *Snapshot) EvaluateFired(out []float64, inputs []float64, resolution ...int) (int, error) {
+	return s.EvaluateRules(out, nil, inputs, resolution...)
+}
+
+// Evaluating the model of the snapshot into out, as EvaluateFired,
+// and the firing strengths of the rules into strengths.
+//
+//	@Params: strengths - one per rule, see
+//			 FuzzyController.RuleStrengths, nil to skip them.
+//
+//	@Return: 1. - the number of rules which fired
+//			 2. - error occurred during the evaluation
+func (s *Snapshot) EvaluateRules(out, strengths []float64, inputs []float64, resolution ...int) (int, error) {
+	if len(out) != len(s.fc.Outputs) {
+		return 0, fmt.Errorf("error by number of outputs, expect %v, got %v", len(s.fc.Outputs), len(out))
+	}
+	if strengths != nil && len(strengths) != len(s.fc.Rules) {
+		return 0, fmt.Errorf("error by number of rule strengths, expect %v, got %v", len(s.fc.Rules), len(strengths))
+	}
+	fc := s.pool.Get().(*FuzzyController)
+	defer s.pool.Put(fc)
+	res, err := fc.Evaluate(inputs, resolution...)
+	if err != nil {
+		return 0, err
+	}
+	copy(out, res)
+	if strengths != nil {
+		if _, err := fc.RuleStrengths(strengths); err != nil {
+			return 0, err
+		}
+	}
+	return fc.Fired(), nil
+}
+
+// Evaluating the model of the snapshot and tracing it, see
+// FuzzyController.Explain.
+func (s *Snapshot) Explain(inputs []float64, resolution ...int) (Explanation, error) {
+	fc := s.pool.Get().(*FuzzyController)
+	defer s.pool.Put(fc)
+	return fc.Explain(inputs, resolution...)
+}
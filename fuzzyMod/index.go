@@ -0,0 +1,161 @@
+package fuzzy
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Index of the rules by the labels of their antecedents, so that
+// only the rules which can fire for the current inputs are
+// computed. A rule joined by "and" fires only if all of its
+// antecedents are active, it is listed under its label of a
+// single pivot input. A rule joined by "or" fires if any of its
+// antecedents is active, it is listed under its labels of all
+// inputs.
+type ruleIndex struct {
+	pivot int
+	and   [][]int   // and[label] - "and" rules by the label of the pivot input
+	or    [][][]int // or[input][label] - "or" rules by the labels of every input
+	// Bit set of the rules to compute, in the order of the rules.
+	marked []uint64
+}
+
+// Switching the indexed evaluation of the rules on or off. With
+// the index, only the rules with active antecedent labels (non-zero
+// membership) are computed, which pays off for large rule bases
+// where few labels of every input are active at a time. The
+// results are identical to the full scan of the rules: whenever
+// some membership of the current inputs is outside [0, 1] or NaN,
+// such as dsigmf dipping below 0, the full scan is used instead.
+// The index is kept by Clone.
+//
+//	@Params: on - true to evaluate with the index, false for the
+//			 full scan.
+func (fc *FuzzyController) SetRuleIndex(on bool) {
+	if !on {
+		fc.index = nil
+		return
+	}
+	if fc.index == nil {
+		fc.index = newRuleIndex(fc)
+	}
+}
+
+// Whether the rules are evaluated with the index, see SetRuleIndex.
+func (fc *FuzzyController) RuleIndex() bool {
+	return fc.index != nil
+}
+
+func newRuleIndex(fc *FuzzyController) *ruleIndex {
+	idx := &ruleIndex{
+		or:     make([][][]int, len(fc.Inputs)),
+		marked: make([]uint64, (len(fc.Rules)+63)/64),
+	}
+	// The input with the most labels is the most selective pivot.
+	for i, mbr := range fc.Inputs {
+		if len(mbr.Mf) > len(fc.Inputs[idx.pivot].Mf) {
+			idx.pivot = i
+		}
+	}
+	labelIndex := make([]map[string]int, len(fc.Inputs))
+	for i, mbr := range fc.Inputs {
+		labelIndex[i] = make(map[string]int, len(mbr.Mf))
+		for j := len(mbr.Mf) - 1; j >= 0; j-- {
+			labelIndex[i][mbr.Mf[j].Label] = j
+		}
+		idx.or[i] = make([][]int, len(mbr.Mf))
+	}
+	if len(fc.Inputs) > 0 {
+		idx.and = make([][]int, len(fc.Inputs[idx.pivot].Mf))
+	}
+
+	for n, r := range fc.Rules {
+		if r.Conjunction == "and" {
+			j := labelIndex[idx.pivot][r.Antecedent[idx.pivot]]
+			idx.and[j] = append(idx.and[j], n)
+		} else {
+			for i, label := range r.Antecedent {
+				j := labelIndex[i][label]
+				idx.or[i][j] = append(idx.or[i][j], n)
+			}
+		}
+	}
+	return idx
+}
+
+// Whether the memberships of the current inputs are all within
+// [0, 1]. Only then a rule with an inactive antecedent fires with
+// exactly 0, which leaves the caps as they are. Rules without
+// antecedents always fire, a model without inputs is scanned.
+func (fc *FuzzyController) sparseInputs() bool {
+	if len(fc.input_mbr) == 0 {
+		return false
+	}
+	for _, mbr := range fc.input_mbr {
+		for _, v := range mbr {
+			if !(v >= 0 && v <= 1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// getCaps with the index. The rules which are not computed fire
+// with 0, which is where the caps start.
+func (fc *FuzzyController) getCapsIndexed() ([][]float64, error) {
+	idx := fc.index
+	caps := fc.caps
+	for i := range caps {
+		for j := range caps[i] {
+			caps[i][j] = 0
+		}
+	}
+
+	for w := range idx.marked {
+		idx.marked[w] = 0
+	}
+	mark := func(rules []int) {
+		for _, n := range rules {
+			idx.marked[n>>6] |= 1 << uint(n&63)
+		}
+	}
+	for j, mf := range fc.Inputs[idx.pivot].Mf {
+		if fc.input_mbr[idx.pivot][mf.Label] != 0 {
+			mark(idx.and[j])
+		}
+	}
+	for i := range idx.or {
+		for j, mf := range fc.Inputs[i].Mf {
+			if fc.input_mbr[i][mf.Label] != 0 {
+				mark(idx.or[i][j])
+			}
+		}
+	}
+
+	// The marked rules in the order of the rules, so that the sums
+	// of sugeno are added up as by the full scan.
+	sugeno := fc.System.Method == "sugeno"
+	fc.fired = 0
+	for w, word := range idx.marked {
+		for word != 0 {
+			n := w<<6 + bits.TrailingZeros64(word)
+			word &= word - 1
+			res, err := fc.strength(fc.Rules[n])
+			if err != nil {
+				return caps, err
+			}
+			if res != 0 {
+				fc.fired++
+			}
+			for i, j := range fc.conseq[n] {
+				if sugeno {
+					caps[i][j] += res
+				} else {
+					caps[i][j] = math.Max(caps[i][j], res)
+				}
+			}
+		}
+	}
+	return caps, nil
+}
@@ -0,0 +1,488 @@
+package fuzzy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Largest number of grid points of a lookup table.
+const maxLUTPoints = 1 << 24
+
+// Lookup table of a fuzzyController, the outputs sampled on a
+// regular grid over the input ranges and evaluated by multilinear
+// interpolation in constant time. The table has no reference to
+// the controller, it can be stored and loaded on its own.
+type LUT struct {
+	// Name of the compiled model.
+	Name string `json:"name"`
+	// Grid of every input.
+	Inputs []LUTAxis `json:"inputs"`
+	// Names of the outputs.
+	Outputs []string `json:"outputs"`
+	// Largest difference to the exact engine of every output,
+	// checked at the centre of every grid cell, where the
+	// interpolation is furthest from the sampled points.
+	MaxError []float64 `json:"maxError"`
+	// Number of cell centres of every output where only one of
+	// the table and the engine is defined, which happens next
+	// to the regions where no rule fires. They are left out of
+	// MaxError.
+	Undefined []int `json:"undefined"`
+	// Outputs at the grid points, the first input varying
+	// slowest and the outputs of a point next to each other.
+	// Undefined outputs (no rule fired) are NaN.
+	Values []float64 `json:"values"`
+}
+
+// Grid of an input, Points points evenly spaced from Min to Max.
+type LUTAxis struct {
+	Name   string  `json:"name"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Points int     `json:"points"`
+}
+
+// Compiling the fuzzyController into a lookup table, sampling the
+// exact engine at the grid points and at the cell centres for the
+// error report. The fuzzyController itself is left untouched.
+//
+//	@Params: grid - the number of grid points of every input, at
+//			 least 2.
+//
+//			 resolution - the resolution of every mamdani output,
+//			 as for Evaluate.
+//
+//	@Return: 1. - the lookup table
+//			 2. - error occurred during the compilation
+func (fc *FuzzyController) CompileLUT(grid []int, resolution ...int) (*LUT, error) {
+	if len(grid) != len(fc.Inputs) {
+		return nil, fmt.Errorf("error by number of grid sizes, expect %v, got %v", len(fc.Inputs), len(grid))
+	}
+	t := &LUT{Name: fc.System.Name}
+	total := 1
+	for i, mbr := range fc.Inputs {
+		if grid[i] < 2 {
+			return nil, fmt.Errorf("error by grid size of %q, expect at least 2, got %v", mbr.Name, grid[i])
+		}
+		total *= grid[i]
+		if total > maxLUTPoints {
+			return nil, fmt.Errorf("error by grid size, more than %v points", maxLUTPoints)
+		}
+		t.Inputs = append(t.Inputs, LUTAxis{Name: mbr.Name, Min: mbr.Range[0], Max: mbr.Range[1], Points: grid[i]})
+	}
+	for _, mbr := range fc.Outputs {
+		t.Outputs = append(t.Outputs, mbr.Name)
+	}
+
+	// A private copy keeps the state of fc as it is.
+	exact := fc.copyModel()
+	if err := exact.init(); err != nil {
+		return nil, err
+	}
+	nOut := len(t.Outputs)
+	t.Values = make([]float64, 0, total*nOut)
+	in := make([]float64, len(t.Inputs))
+	idx := make([]int, len(t.Inputs))
+	for n := 0; n < total; n++ {
+		for i := range idx {
+			in[i] = t.Inputs[i].at(float64(idx[i]))
+		}
+		out, err := exact.Evaluate(in, resolution...)
+		if err != nil {
+			return nil, err
+		}
+		t.Values = append(t.Values, out...)
+		t.next(idx, 0)
+	}
+
+	// Cell centres.
+	t.MaxError = make([]float64, nOut)
+	t.Undefined = make([]int, nOut)
+	got := make([]float64, nOut)
+	for i := range idx {
+		idx[i] = 0
+	}
+	for cells, n := t.cells(), 0; n < cells; n++ {
+		for i := range idx {
+			in[i] = t.Inputs[i].at(float64(idx[i]) + 0.5)
+		}
+		want, err := exact.Evaluate(in, resolution...)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.EvalTo(got, in); err != nil {
+			return nil, err
+		}
+		for o := range got {
+			switch {
+			case math.IsNaN(want[o]) && math.IsNaN(got[o]):
+			case math.IsNaN(want[o]) || math.IsNaN(got[o]):
+				t.Undefined[o]++
+			default:
+				t.MaxError[o] = math.Max(t.MaxError[o], math.Abs(got[o]-want[o]))
+			}
+		}
+		t.next(idx, 1)
+	}
+	return t, nil
+}
+
+// Interpolating the outputs at the given inputs, which are kept
+// inside the input ranges as by the fuzzyController.
+//
+//	@Params: inputs - the input values.
+//
+//	@Return: 1. - the output values
+//			 2. - error occurred during the interpolation
+func (t *LUT) Eval(inputs []float64) ([]float64, error) {
+	out := make([]float64, len(t.Outputs))
+	if err := t.EvalTo(out, inputs); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Interpolating the outputs into out, without allocating.
+//
+//	@Params: out - the output values, one per output.
+//
+//			 inputs - the input values.
+func (t *LUT) EvalTo(out []float64, inputs []float64) error {
+	dims := len(t.Inputs)
+	if len(inputs) != dims {
+		return fmt.Errorf("error by number of input values, expect %v, got %v", dims, len(inputs))
+	}
+	if len(out) != len(t.Outputs) {
+		return fmt.Errorf("error by number of output values, expect %v, got %v", len(t.Outputs), len(out))
+	}
+	// Lower corner of the cell, position inside the cell and
+	// stride of every input. 64 inputs are far beyond any table
+	// fitting into memory.
+	var (
+		base   int
+		frac   [64]float64
+		stride [64]int
+	)
+	if dims > len(frac) {
+		return fmt.Errorf("error by number of inputs, at most %v supported", len(frac))
+	}
+	s := len(t.Outputs)
+	for i := dims - 1; i >= 0; i-- {
+		ax := t.Inputs[i]
+		if math.IsNaN(inputs[i]) {
+			for o := range out {
+				out[o] = math.NaN()
+			}
+			return nil
+		}
+		x := math.Min(math.Max(inputs[i], ax.Min), ax.Max)
+		pos := (x - ax.Min) / (ax.Max - ax.Min) * float64(ax.Points-1)
+		cell := int(pos)
+		if cell > ax.Points-2 {
+			cell = ax.Points - 2
+		}
+		frac[i] = pos - float64(cell)
+		stride[i] = s
+		base += cell * s
+		s *= ax.Points
+	}
+	for o := range out {
+		out[o] = 0
+	}
+	// Summing up the 2^dims corners of the cell, the corners of
+	// zero weight are skipped, so an undefined point does not
+	// spoil its neighbouring cells.
+	for corner := 0; corner < 1<<uint(dims); corner++ {
+		w, offset := 1.0, base
+		for i := 0; i < dims; i++ {
+			if corner&(1<<uint(i)) != 0 {
+				w *= frac[i]
+				offset += stride[i]
+			} else {
+				w *= 1 - frac[i]
+			}
+		}
+		if w == 0 {
+			continue
+		}
+		for o := range out {
+			out[o] += w * t.Values[offset+o]
+		}
+	}
+	return nil
+}
+
+// Magic number and version of the binary encoding.
+const (
+	lutMagic   = "FZLT"
+	lutVersion = 1
+)
+
+// Binary encoding of the table, little endian: the magic "FZLT",
+// the format version, the name, the inputs (name, min, max,
+// points), the output names, the maximum errors, the undefined
+// counts and the values.
+// Strings are prefixed with their length, lists with their size.
+func (t *LUT) MarshalBinary() ([]byte, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString(lutMagic)
+	write := func(v interface{}) { binary.Write(&b, binary.LittleEndian, v) }
+	writeString := func(s string) {
+		write(uint32(len(s)))
+		b.WriteString(s)
+	}
+	write(uint16(lutVersion))
+	writeString(t.Name)
+	write(uint32(len(t.Inputs)))
+	for _, ax := range t.Inputs {
+		writeString(ax.Name)
+		write(ax.Min)
+		write(ax.Max)
+		write(uint32(ax.Points))
+	}
+	write(uint32(len(t.Outputs)))
+	for _, name := range t.Outputs {
+		writeString(name)
+	}
+	write(t.MaxError)
+	for _, n := range t.Undefined {
+		write(uint32(n))
+	}
+	write(t.Values)
+	return b.Bytes(), nil
+}
+
+// Decoding a table written by MarshalBinary.
+func (t *LUT) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(lutMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != lutMagic {
+		return errors.New("error by decoding the lookup table: not a lookup table")
+	}
+	var err error
+	read := func(v interface{}) {
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, v)
+		}
+	}
+	// Sizes are checked against the remaining data before
+	// allocating, a corrupted size does not exhaust the memory.
+	readSize := func(elem int) int {
+		var n uint32
+		read(&n)
+		if err == nil && int64(n)*int64(elem) > int64(r.Len()) {
+			err = io.ErrUnexpectedEOF
+		}
+		return int(n)
+	}
+	readString := func() string {
+		buf := make([]byte, readSize(1))
+		if err == nil {
+			_, err = io.ReadFull(r, buf)
+		}
+		return string(buf)
+	}
+	var version uint16
+	read(&version)
+	if err == nil && version != lutVersion {
+		return fmt.Errorf("error by decoding the lookup table: unknown version %v", version)
+	}
+	lt := LUT{Name: readString()}
+	lt.Inputs = make([]LUTAxis, readSize(20))
+	for i := range lt.Inputs {
+		var points uint32
+		lt.Inputs[i].Name = readString()
+		read(&lt.Inputs[i].Min)
+		read(&lt.Inputs[i].Max)
+		read(&points)
+		lt.Inputs[i].Points = int(points)
+	}
+	lt.Outputs = make([]string, readSize(4))
+	for i := range lt.Outputs {
+		lt.Outputs[i] = readString()
+	}
+	if err != nil {
+		return fmt.Errorf("error by decoding the lookup table: %v", err)
+	}
+	size, err := lt.size()
+	if err != nil {
+		return err
+	}
+	if int64(len(lt.Outputs))*12+int64(size)*8 != int64(r.Len()) {
+		return errors.New("error by decoding the lookup table: size of the values does not match the grid")
+	}
+	lt.MaxError = make([]float64, len(lt.Outputs))
+	undefined := make([]uint32, len(lt.Outputs))
+	lt.Values = make([]float64, size)
+	read(lt.MaxError)
+	read(undefined)
+	read(lt.Values)
+	for _, n := range undefined {
+		lt.Undefined = append(lt.Undefined, int(n))
+	}
+	if err != nil {
+		return fmt.Errorf("error by decoding the lookup table: %v", err)
+	}
+	*t = lt
+	return nil
+}
+
+// Encoding the table in json, with the undefined values and
+// errors as strings.
+func (t LUT) MarshalJSON() ([]byte, error) {
+	// table has the fields of LUT but not its methods, which
+	// keeps json.Marshal from recursing.
+	type table LUT
+	return json.Marshal(struct {
+		table
+		MaxError lutFloats `json:"maxError"`
+		Values   lutFloats `json:"values"`
+	}{table(t), t.MaxError, t.Values})
+}
+
+// Decoding a table written by json.Marshal, checking that the
+// values fit the grid.
+func (t *LUT) UnmarshalJSON(data []byte) error {
+	type table LUT
+	var lt struct {
+		table
+		MaxError lutFloats `json:"maxError"`
+		Values   lutFloats `json:"values"`
+	}
+	if err := json.Unmarshal(data, &lt); err != nil {
+		return err
+	}
+	lt.table.MaxError, lt.table.Values = lt.MaxError, lt.Values
+	if err := (*LUT)(&lt.table).check(); err != nil {
+		return err
+	}
+	*t = LUT(lt.table)
+	return nil
+}
+
+// Checking that the grid is valid and the values fit it.
+func (t *LUT) check() error {
+	size, err := t.size()
+	if err != nil {
+		return err
+	}
+	if len(t.Values) != size || len(t.MaxError) != len(t.Outputs) || len(t.Undefined) != len(t.Outputs) {
+		return errors.New("error by lookup table: size of the values does not match the grid")
+	}
+	return nil
+}
+
+// Number of values of the grid.
+func (t *LUT) size() (int, error) {
+	if len(t.Inputs) == 0 || len(t.Outputs) == 0 {
+		return 0, errors.New("error by lookup table: no inputs or outputs")
+	}
+	size := len(t.Outputs)
+	for _, ax := range t.Inputs {
+		if ax.Points < 2 || !(ax.Min < ax.Max) {
+			return 0, fmt.Errorf("error by lookup table: invalid grid of %q", ax.Name)
+		}
+		size *= ax.Points
+		if size > maxLUTPoints*len(t.Outputs) {
+			return 0, fmt.Errorf("error by lookup table: more than %v points", maxLUTPoints)
+		}
+	}
+	return size, nil
+}
+
+// Number of cells of the grid.
+func (t *LUT) cells() int {
+	n := 1
+	for _, ax := range t.Inputs {
+		n *= ax.Points - 1
+	}
+	return n
+}
+
+// Advancing the multi-index of the grid points (skip 0) or the
+// cells (skip 1), the last input varying fastest.
+func (t *LUT) next(idx []int, skip int) {
+	for i := len(idx) - 1; i >= 0; i-- {
+		idx[i]++
+		if idx[i] < t.Inputs[i].Points-skip {
+			return
+		}
+		idx[i] = 0
+	}
+}
+
+// Position k (possibly fractional) of the grid.
+func (ax LUTAxis) at(k float64) float64 {
+	if k == float64(ax.Points-1) {
+		return ax.Max
+	}
+	return ax.Min + k*(ax.Max-ax.Min)/float64(ax.Points-1)
+}
+
+// Numbers of a lookup table in json, which has no literals for
+// NaN and the infinities: they are written as the strings "NaN",
+// "+Inf" and "-Inf".
+type lutFloats []float64
+
+func (fs lutFloats) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, v := range fs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch {
+		case math.IsNaN(v):
+			b.WriteString(`"NaN"`)
+		case math.IsInf(v, 1):
+			b.WriteString(`"+Inf"`)
+		case math.IsInf(v, -1):
+			b.WriteString(`"-Inf"`)
+		default:
+			num, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			b.Write(num)
+		}
+	}
+	b.WriteByte(']')
+	return b.Bytes(), nil
+}
+
+func (fs *lutFloats) UnmarshalJSON(data []byte) error {
+	var raw []interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	out := make(lutFloats, len(raw))
+	for i, v := range raw {
+		switch v := v.(type) {
+		case float64:
+			out[i] = v
+		case string:
+			switch v {
+			case "NaN":
+				out[i] = math.NaN()
+			case "+Inf":
+				out[i] = math.Inf(1)
+			case "-Inf":
+				out[i] = math.Inf(-1)
+			default:
+				return fmt.Errorf("error by lookup table: invalid number %q", v)
+			}
+		default:
+			return fmt.Errorf("error by lookup table: invalid number %v", v)
+		}
+	}
+	*fs = out
+	return nil
+}
@@ -0,0 +1,78 @@
+package fuzzy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Encoding the fuzzyController in the same json schema that
+// NewFuzzyController reads. Only the model is written, the
+// derived data (membership function lists, and/or functions,
+// memberships and aggregation buffers) is left out, and the
+// fields follow the declaration order of the types, so the
+// output is stable between runs.
+func (fc FuzzyController) MarshalJSON() ([]byte, error) {
+	// model has the fields of FuzzyController but not its
+	// methods, which keeps json.Marshal from recursing.
+	type model FuzzyController
+	m := model(fc)
+	m.Version = ModelVersion
+	// The counts always describe the written model.
+	m.System.Numinputs = len(fc.Inputs)
+	m.System.Numoutputs = len(fc.Outputs)
+	m.System.Numrules = len(fc.Rules)
+	return json.Marshal(m)
+}
+
+// Encoding the fuzzyController as an indented json string,
+// the layout used by the model files of this repository.
+//
+//	@Return: 1. - the json string, ending with a newline
+//			 2. - error occurred during the encoding
+func (fc *FuzzyController) ToJSON() (string, error) {
+	b, err := json.MarshalIndent(fc, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// Writing the fuzzyController to a json model file, which
+// can be read again with NewFuzzyController. The file is
+// replaced atomically, readers never see a partial model.
+//
+//	@Params: path - the file to be (over)written.
+func (fc *FuzzyController) Save(path string) error {
+	str, err := fc.ToJSON()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(str))
+}
+
+// Writing a file through a temporary file in the same directory,
+// which is renamed over the target once completely written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,176 @@
+package fuzzy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Version of the model format written by MarshalJSON.
+//
+//	1 - models without a `version` field.
+//	2 - methods, membership function types and conjunctions
+//	    are lower case keywords.
+const ModelVersion = 2
+
+// Returned by ModelFileVersion for files which are not models.
+var ErrNotModel = errors.New("not a fuzzy model")
+
+// migrations[v] upgrades a model document from version v to v+1.
+var migrations = map[int]func(doc map[string]interface{}) error{
+	1: migrateV1,
+}
+
+// Upgrading a decoded model document to ModelVersion in place.
+//
+//	@Return: 1. - the version of the document before migration
+//			 2. - error if the version is unknown or the
+//			 migration failed
+func migrate(doc map[string]interface{}) (int, error) {
+	from, err := documentVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+	if from > ModelVersion {
+		return from, fmt.Errorf("error by model version, %v is newer than the supported %v",
+			from, ModelVersion)
+	}
+	for v := from; v < ModelVersion; v++ {
+		if err := migrations[v](doc); err != nil {
+			return from, fmt.Errorf("error by migrating the model from version %v: %v", v, err)
+		}
+	}
+	doc["version"] = ModelVersion
+	return from, nil
+}
+
+// The `version` of a model document, 1 if absent.
+func documentVersion(doc map[string]interface{}) (int, error) {
+	value, ok := doc["version"]
+	if !ok {
+		return 1, nil
+	}
+	var (
+		v   int64
+		err error
+	)
+	switch n := value.(type) {
+	case json.Number:
+		v, err = n.Int64()
+	case int:
+		v = int64(n)
+	case int64:
+		v = n
+	case float64:
+		v = int64(n)
+		if float64(v) != n {
+			err = fmt.Errorf("%v is not an integer", n)
+		}
+	default:
+		err = fmt.Errorf("%v is not a number", value)
+	}
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("error by model version: invalid version %v", value)
+	}
+	return int(v), nil
+}
+
+// Version 1 compared some keywords case-insensitively, version 2
+// only accepts the lower case ones.
+func migrateV1(doc map[string]interface{}) error {
+	if sys, ok := doc["system"].(map[string]interface{}); ok {
+		for _, key := range []string{
+			"method", "andMethod", "orMethod", "impMethod", "aggMethod", "defuzzMethod",
+		} {
+			lowerField(sys, key)
+		}
+	}
+	for _, key := range []string{"input", "output"} {
+		mbrs, _ := doc[key].([]interface{})
+		for _, mbr := range mbrs {
+			mbr, _ := mbr.(map[string]interface{})
+			mfs, _ := mbr["mf"].([]interface{})
+			for _, mf := range mfs {
+				if mf, ok := mf.(map[string]interface{}); ok {
+					lowerField(mf, "type")
+				}
+			}
+		}
+	}
+	rules, _ := doc["rules"].([]interface{})
+	for _, r := range rules {
+		if r, ok := r.(map[string]interface{}); ok {
+			lowerField(r, "conjunction")
+		}
+	}
+	return nil
+}
+
+func lowerField(m map[string]interface{}, key string) {
+	if s, ok := m[key].(string); ok {
+		m[key] = strings.ToLower(s)
+	}
+}
+
+// Reading the version of a json, yaml or toml model file.
+//
+//	@Return: 1. - the version, 1 for files without a version
+//			 2. - ErrNotModel if the file is not a model,
+//			 or the error occurred while reading it
+func ModelFileVersion(path string) (int, error) {
+	doc, _, err := readDocument(path)
+	if err != nil {
+		return 0, err
+	}
+	return documentVersion(doc)
+}
+
+// Upgrading a json model file to ModelVersion, the file is
+// rewritten with Save only if it is of an older version.
+//
+//	@Return: 1. - whether the file has been rewritten
+//			 2. - error occurred during the migration
+func MigrateModelFile(path string) (bool, error) {
+	doc, format, err := readDocument(path)
+	if err != nil {
+		return false, err
+	}
+	version, err := documentVersion(doc)
+	if err != nil || version >= ModelVersion {
+		return false, err
+	}
+	if format != FormatJSON {
+		return false, fmt.Errorf("%v: only json model files can be rewritten", path)
+	}
+	fc, err := newFromDocument(doc)
+	if err != nil {
+		return false, fmt.Errorf("%v: %v", path, err)
+	}
+	if err := fc.Save(path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reading a model file into a generic document. Files without
+// a `system` section are reported as ErrNotModel.
+func readDocument(path string) (map[string]interface{}, string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	format := fileFormat(path, content)
+	if format == FormatFLL {
+		return nil, format, ErrNotModel
+	}
+	doc, err := decodeDocument(string(content), format)
+	if err != nil {
+		return nil, format, fmt.Errorf("%v: %v", path, err)
+	}
+	if _, ok := doc["system"]; !ok {
+		return nil, format, ErrNotModel
+	}
+	return doc, format, nil
+}
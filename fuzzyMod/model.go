@@ -1,49 +1,60 @@
 package fuzzy
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"strings"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type FuzzyController struct {
+	Version   int      `json:"version,omitempty"`
 	System    config   `json:"system"`
 	Inputs    []member `json:"input"`
 	Outputs   []member `json:"output"`
 	Rules     []rule   `json:"rules"`
 	input_mbr []map[string]float64
+	caps      [][]float64 // caps[output][label], labels in the order of the model
+	named     [][]bool    // named[output][label], whether some rule names the label
+	conseq    [][]int     // conseq[rule][output], index of the consequent label
+	fired     int         // rules with non-zero strength in the last evaluation
 	aggX      [][]float64
 	aggY      [][]float64
+	aggRes    []int // resolution the grids aggX were built with
 	andFn     func(float64, float64) float64
 	orFn      func(float64, float64) float64
+	impFn     func(float64, float64) float64
+	aggFn     func(float64, float64) float64
+	defRes    []int // DefaultResolution for every output
+	index     *ruleIndex
 	result    []float64
 }
 type config struct {
 	Name         string `json:"name"`
 	Method       string `json:"method"`
-	Numinputs    int    `json:"numInputs"`
-	Numoutputs   int    `json:"numOutputs"`
-	Numrules     int    `json:"numRules"`
+	Numinputs    int    `json:"numInputs,omitempty"`
+	Numoutputs   int    `json:"numOutputs,omitempty"`
+	Numrules     int    `json:"numRules,omitempty"`
 	Andmethod    string `json:"andMethod"`
 	Ormethod     string `json:"orMethod"`
-	Impmethod    string `json:"impMethod"`
-	Aggmethod    string `json:"aggMethod"`
+	Impmethod    string `json:"impMethod,omitempty"`
+	Aggmethod    string `json:"aggMethod,omitempty"`
 	Defuzzmethod string `json:"defuzzMethod"`
 }
 type memberFunction struct {
 	Label  string    `json:"label"`
 	Type   string    `json:"type"`
 	Params []float64 `json:"params"`
+
+	fn func(float64) float64 // compiled by init, nil for constants
 }
 type member struct {
-	Name    string           `json:"name"`
-	Range   []float64        `json:"range"`
-	Mf      []memberFunction `json:"mf"`
-	Mf_list map[string]memberFunction
+	Name    string                    `json:"name"`
+	Range   []float64                 `json:"range"`
+	Mf      []memberFunction          `json:"mf"`
+	Mf_list map[string]memberFunction `json:"-"`
 }
 type rule struct {
 	Antecedent  []string `json:"antecedent"`
@@ -56,32 +67,110 @@ type rule struct {
 //
 //	@Params: jsonStr - Json format string, containing
 //			 `System information`, `inputs`, `outputs`
-//			 and `rules` for the fuzzy model
-// 	@Return: fuzzyController object with auto generated
+//			 and `rules` for the fuzzy model. Unknown
+//			 fields are rejected, the counts `numInputs`,
+//			 `numOutputs` and `numRules` are optional.
+//	@Return: fuzzyController object with auto generated
 //			 output memebership function list and
 //			 and/or functions.
 func NewFuzzyController(jsonStr string) (FuzzyController, error) {
 
 	// Initializing the fuzzyController object.
+	doc, err := decodeDocument(jsonStr, FormatJSON)
+	if err != nil {
+		return FuzzyController{}, err
+	}
+	return newFromDocument(doc)
+}
+
+// Creating the fuzzyController from a decoded model document of
+// any format. Older documents are migrated to ModelVersion first,
+// then the document is decoded strictly into the model types.
+func newFromDocument(doc map[string]interface{}) (FuzzyController, error) {
 	var fc FuzzyController
-	json.Unmarshal([]byte(jsonStr), &fc)
+	if _, err := migrate(doc); err != nil {
+		return fc, err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return fc, fmt.Errorf("error by decoding the model: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return fc, fmt.Errorf("error by decoding the model: %v", err)
+	}
+	err = fc.init()
+	return fc, err
+}
+
+// Checking the decoded model and generating the derived
+// data (membership function lists, and/or functions and
+// buffers) shared by all the model readers.
+func (fc *FuzzyController) init() error {
+	// Models created in Go are of the current version.
+	if fc.Version == 0 {
+		fc.Version = ModelVersion
+	}
+	if fc.Version != ModelVersion {
+		return fmt.Errorf("error by model version, expect %v, got %v", ModelVersion, fc.Version)
+	}
+
+	// The counts are redundant, they are inferred when
+	// absent and cross-checked otherwise.
+	if fc.System.Numinputs == 0 {
+		fc.System.Numinputs = len(fc.Inputs)
+	}
+	if fc.System.Numoutputs == 0 {
+		fc.System.Numoutputs = len(fc.Outputs)
+	}
+	if fc.System.Numrules == 0 {
+		fc.System.Numrules = len(fc.Rules)
+	}
 
 	// Return error if number of inputs/ outputs
 	// doesn't match with the setup
 	if fc.System.Numinputs != len(fc.Inputs) {
-		return fc, fmt.Errorf(
+		return fmt.Errorf(
 			"error by number of input values, expect %v, got %v",
 			fc.System.Numinputs,
 			len(fc.Inputs),
 		)
 	} // Inputs
 	if fc.System.Numoutputs != len(fc.Outputs) {
-		return fc, fmt.Errorf(
+		return fmt.Errorf(
 			"error by number of output values, expect %v, got %v",
 			fc.System.Numoutputs,
 			len(fc.Outputs),
 		)
 	} // Outputs
+	if fc.System.Numrules != len(fc.Rules) {
+		return fmt.Errorf(
+			"error by number of rules, expect %v, got %v",
+			fc.System.Numrules,
+			len(fc.Rules),
+		)
+	} // Rules
+	if err := fc.validate(); err != nil {
+		return err
+	}
+
+	// Compiling the membership functions once, evaluations only
+	// call them.
+	for _, mbrs := range [][]member{fc.Inputs, fc.Outputs} {
+		for i := range mbrs {
+			for j, mf := range mbrs[i].Mf {
+				if mf.Type == "constant" {
+					continue
+				}
+				fn, err := MemberFuncWrapper(mf.Type, mf.Params)
+				if err != nil {
+					return err
+				}
+				mbrs[i].Mf[j].fn = fn
+			}
+		}
+	}
 
 	// Creating the membership function list for outputs
 	// -- for later use of hash search.
@@ -99,7 +188,7 @@ func NewFuzzyController(jsonStr string) (FuzzyController, error) {
 	} else if fc.System.Andmethod == "min" {
 		fc.andFn = math.Min
 	} else {
-		return fc, fmt.Errorf(
+		return fmt.Errorf(
 			`error by "and" method, only "min" or "prod" are acceptable, got %v`,
 			fc.System.Andmethod,
 		)
@@ -111,16 +200,51 @@ func NewFuzzyController(jsonStr string) (FuzzyController, error) {
 	} else if fc.System.Ormethod == "sum" {
 		fc.orFn = func(x float64, y float64) float64 { return x + y }
 	} else {
-		return fc, fmt.Errorf(
+		return fmt.Errorf(
 			`error by "or" method, only "probor", "sum" or "max" are acceptable, got %v`,
 			fc.System.Ormethod,
 		)
 	} // OR function
 
-	// Memory allocation for necessay values.
+	// The implication and aggregation of mamdani models.
+	fc.impFn = normFunc(fc.System.Impmethod)
+	fc.aggFn = normFunc(fc.System.Aggmethod)
+
+	// Memory allocation for necessay values. The buffers are
+	// reused by every evaluation, which allocates nothing once
+	// the grids of the requested resolution are built.
+	fc.input_mbr = make([]map[string]float64, fc.System.Numinputs)
+	for i := range fc.input_mbr {
+		fc.input_mbr[i] = make(map[string]float64, len(fc.Inputs[i].Mf))
+	}
+	fc.caps = make([][]float64, fc.System.Numoutputs)
+	fc.named = make([][]bool, fc.System.Numoutputs)
+	for i := range fc.caps {
+		fc.caps[i] = make([]float64, len(fc.Outputs[i].Mf))
+		fc.named[i] = make([]bool, len(fc.Outputs[i].Mf))
+	}
+	fc.conseq = make([][]int, len(fc.Rules))
+	for n, r := range fc.Rules {
+		fc.conseq[n] = make([]int, len(r.Consequent))
+		for i, label := range r.Consequent {
+			for j, mf := range fc.Outputs[i].Mf {
+				if mf.Label == label {
+					fc.conseq[n][i] = j
+					fc.named[i][j] = true
+					break
+				}
+			}
+		}
+	}
 	fc.aggX = make([][]float64, fc.System.Numoutputs)
 	fc.aggY = make([][]float64, fc.System.Numoutputs)
-	return fc, nil
+	fc.aggRes = make([]int, fc.System.Numoutputs)
+	fc.defRes = make([]int, fc.System.Numoutputs)
+	for i := range fc.defRes {
+		fc.defRes[i] = DefaultResolution
+	}
+	fc.result = make([]float64, fc.System.Numoutputs)
+	return nil
 }
 
 // Feeding input values to the fuzzyController object.
@@ -128,7 +252,7 @@ func NewFuzzyController(jsonStr string) (FuzzyController, error) {
 // calculated membership value in form of maps.
 //
 //	@Params: inputs - The input values in form of a float64
-// 			 array.
+//			 array.
 func (fc *FuzzyController) SetInputs(inputs []float64) error {
 	// Return error if the number of inputs doesn't match with
 	// the model setup.
@@ -140,24 +264,16 @@ func (fc *FuzzyController) SetInputs(inputs []float64) error {
 	}
 
 	// Calculate the membership for the input values.
-	fc.input_mbr = nil
 	for i, value := range inputs {
 		// Keep the input values inside the input range.
 		limit := fc.Inputs[i].Range
 		value = math.Min(math.Max(value, limit[0]), limit[1])
-		// Calculate the memberships for every input value.
-		mbr := make(map[string]float64)
+		// Calculate the memberships for every input value,
+		// input_mbr stores the memberships for all inputs.
+		mbr := fc.input_mbr[i]
 		for _, mf := range fc.Inputs[i].Mf { // mf - MbrFns for current input.
-			fn, err := MemberFuncWrapper(mf.Type, mf.Params)
-			if err != nil {
-				return err
-			}
-			res := fn(value)
-			// Save result to a map.
-			mbr[mf.Label] = res
+			mbr[mf.Label] = mf.fn(value)
 		}
-		// input_mbr stores the memberships for all inputs.
-		fc.input_mbr = append(fc.input_mbr, mbr)
 	}
 	return nil
 }
@@ -177,43 +293,52 @@ func (fc *FuzzyController) SetInputs(inputs []float64) error {
 //
 //	@Return: error occurred during the aggregation.
 func (fc *FuzzyController) AggregateMamdani(resolution []int) error {
+	if len(resolution) != fc.System.Numoutputs {
+		return fmt.Errorf(
+			"error by number of resolutions, expect %v, got %v",
+			fc.System.Numoutputs,
+			len(resolution))
+	}
 	caps, err := fc.getCaps()
 	if err != nil {
 		return err
 	}
+	if fc.impFn == nil || fc.aggFn == nil {
+		return fmt.Errorf("unknown implication %q or aggregation %q method",
+			fc.System.Impmethod, fc.System.Aggmethod)
+	}
 	// The cap values are implemented to the total membership values of the outputs.
 	for i, v := range caps {
-		// Parse the function types and thier cap value into arrays
-		var (
-			mfs []func(float64) float64
-			cap []float64
-		)
-		// v : map["consequent"] -> cap value
-		// CAUTION: Traverse order uncertain
-		for key, value := range v {
-			// Getting the membreship function using key word
-			fn, err := MemberFuncWrapper(
-				fc.Outputs[i].Mf_list[key].Type,
-				fc.Outputs[i].Mf_list[key].Params)
+		// The grid is built once per resolution, the curve
+		// reuses its buffer. The resolution is checked first, a
+		// fresh fuzzyController has none cached.
+		if resolution[i] <= 1 {
+			return errResolution
+		}
+		if fc.aggRes[i] != resolution[i] {
+			x, err := grid(fc.Outputs[i].Range[0], fc.Outputs[i].Range[1], resolution[i])
 			if err != nil {
 				return err
 			}
-			// push function and correspoding cap value to arrays
-			mfs = append(mfs, fn)
-			cap = append(cap, value)
+			fc.aggX[i] = x
+			fc.aggY[i] = make([]float64, len(x))
+			fc.aggRes[i] = resolution[i]
 		}
-		// Calculating the aggregation function
-		a, b, err := aggr(
-			fc.Outputs[i].Range[0], fc.Outputs[i].Range[1], resolution[i],
-			mfs, cap,
-			fc.System.Impmethod, fc.System.Aggmethod,
-		)
-		if err != nil {
-			log.Fatal(err)
+		x, y := fc.aggX[i], fc.aggY[i]
+		for idx := range y {
+			y[idx] = 0
+		}
+		// The labels in the order of the model, those not
+		// named by any rule take no part.
+		for j, mf := range fc.Outputs[i].Mf {
+			if !fc.named[i][j] {
+				continue
+			}
+			cap := v[j]
+			for idx, xv := range x {
+				y[idx] = fc.aggFn(y[idx], fc.impFn(mf.fn(xv), cap))
+			}
 		}
-		// Saving the result to type properties
-		fc.aggX[i] = a
-		fc.aggY[i] = b
 	}
 	return nil
 }
@@ -223,14 +348,18 @@ func (fc *FuzzyController) AggregateSugeno() error {
 	if err != nil {
 		return err
 	}
-	var rst []float64
+	rst := fc.result[:0]
 	// The cap values are implemented to the total membership values of the outputs.
 	for i, v := range caps {
 		sum, den := 0., 0.
-		// v : map["consequent"] -> cap value
-		// CAUTION: Traverse order uncertain
-		for key, value := range v {
-			sum += fc.Outputs[i].Mf_list[key].Params[0] * value
+		// v : cap values of the labels, summed up in the order
+		// of the model for reproducible results.
+		for j, mf := range fc.Outputs[i].Mf {
+			if !fc.named[i][j] {
+				continue
+			}
+			value := v[j]
+			sum += mf.Params[0] * value
 			den += value
 		}
 		if fc.System.Defuzzmethod == "wtaver" {
@@ -253,10 +382,13 @@ func (fc *FuzzyController) AggregateSugeno() error {
 //
 //			 resolution - the "step size" for x values of the curves
 //
-//	@Return: error occurred during the aggregation.
+//	@Return: 1. - the output values, in a buffer of the
+//			 fuzzyController which the next evaluation
+//			 overwrites. Copy them to keep them.
+//			 2. - error occurred during the defuzzification.
 func (fc *FuzzyController) GetResult() ([]float64, error) {
 	if fc.System.Method == "mamdani" {
-		ret := make([]float64, fc.System.Numoutputs)
+		ret := fc.result
 		for i := range fc.aggX {
 			defuzz := 0.
 
@@ -264,31 +396,31 @@ func (fc *FuzzyController) GetResult() ([]float64, error) {
 			case "centroid":
 				cenPoint, err := Centroid(fc.aggX[i], fc.aggY[i])
 				if err != nil {
-					log.Fatal(err)
+					return nil, defuzzError(fc.Outputs[i].Name, err)
 				}
 				defuzz = cenPoint
 			case "bisector":
 				biPoint, err := Bisector(fc.aggX[i], fc.aggY[i])
 				if err != nil {
-					log.Fatal(err)
+					return nil, defuzzError(fc.Outputs[i].Name, err)
 				}
 				defuzz = biPoint
-			case "MOM":
+			case "mom":
 				biPoint, err := MOMdefuzz(fc.aggX[i], fc.aggY[i])
 				if err != nil {
-					log.Fatal(err)
+					return nil, defuzzError(fc.Outputs[i].Name, err)
 				}
 				defuzz = biPoint
-			case "SOM":
+			case "som":
 				biPoint, err := SOMdefuzz(fc.aggX[i], fc.aggY[i])
 				if err != nil {
-					log.Fatal(err)
+					return nil, defuzzError(fc.Outputs[i].Name, err)
 				}
 				defuzz = biPoint
-			case "LOM":
+			case "lom":
 				biPoint, err := LOMdefuzz(fc.aggX[i], fc.aggY[i])
 				if err != nil {
-					log.Fatal(err)
+					return nil, defuzzError(fc.Outputs[i].Name, err)
 				}
 				defuzz = biPoint
 			}
@@ -303,65 +435,162 @@ func (fc *FuzzyController) GetResult() ([]float64, error) {
 	}
 }
 
-func (fc *FuzzyController) getCaps() ([]map[string]float64, error) {
-	// The container to store the cap value of the output membership.
-	caps := make([]map[string]float64, fc.System.Numoutputs)
-	// Initializing the elements in the array.
+// Number of rules which fired, with a non-zero strength, in the
+// last evaluation. With none, the outputs are undefined: NaN for
+// wtaver and centroid, and arbitrary points of the range for the
+// other defuzzification methods.
+func (fc *FuzzyController) Fired() int {
+	return fc.fired
+}
+
+// Firing strengths of the rules in the last evaluation, in the
+// order of the rules. They are computed again from the memberships
+// of the last inputs, so evaluations cost nothing extra for them.
+//
+//	@Params: dst - buffer of the strengths, used if it is large
+//			 enough.
+//
+//	@Return: 1. - the strengths, one per rule
+//			 2. - error by the conjunction of a rule
+func (fc *FuzzyController) RuleStrengths(dst []float64) ([]float64, error) {
+	if cap(dst) < len(fc.Rules) {
+		dst = make([]float64, len(fc.Rules))
+	}
+	dst = dst[:len(fc.Rules)]
+	for k, r := range fc.Rules {
+		res, err := fc.strength(r)
+		if err != nil {
+			return nil, err
+		}
+		dst[k] = res
+	}
+	return dst, nil
+}
+
+func defuzzError(output string, err error) error {
+	return fmt.Errorf("error by defuzzification of output %q: %v", output, err)
+}
+
+// Default resolution of the mamdani output curves, used by
+// Evaluate when no resolution is given.
+const DefaultResolution = 1000
+
+// Running the whole calculation for one input vector, the
+// same as SetInputs, AggregateMamdani/AggregateSugeno and
+// GetResult in a row. Once the grids of the resolution are
+// built, the evaluation allocates nothing: the buffers are kept
+// in the fuzzyController and shared by its copies, use Clone
+// for evaluations in parallel.
+//
+//	@Params: inputs - the input values.
+//
+//			 resolution - the resolution of every mamdani output,
+//			 DefaultResolution for all of them if omitted.
+//			 Ignored by sugeno.
+//
+//	@Return: 1. - the output values, overwritten by the next
+//			 evaluation as those of GetResult
+//			 2. - error occurred during the calculation
+func (fc *FuzzyController) Evaluate(inputs []float64, resolution ...int) ([]float64, error) {
+	if err := fc.SetInputs(inputs); err != nil {
+		return nil, err
+	}
+	switch fc.System.Method {
+	case "mamdani":
+		if len(resolution) == 0 {
+			resolution = fc.defRes
+		}
+		if err := fc.AggregateMamdani(resolution); err != nil {
+			return nil, err
+		}
+	case "sugeno":
+		if err := fc.AggregateSugeno(); err != nil {
+			return nil, err
+		}
+	}
+	return fc.GetResult()
+}
+
+// Cap values of the output labels for the current inputs. The
+// rules are processed in the order of the model, the caps of a
+// label are combined (max for mamdani, sum for sugeno) in that
+// order, so identical inputs give bit-identical caps. Labels not
+// named by any rule keep the starting value and take no part in
+// the aggregation.
+func (fc *FuzzyController) getCaps() ([][]float64, error) {
+	if fc.index != nil && fc.sparseInputs() {
+		return fc.getCapsIndexed()
+	}
+
+	// The container to store the cap value of the output membership,
+	// reset for the current inputs: -Inf is the identity of max,
+	// -0 the identity of the sum, so the first rule of a label sets
+	// its cap as it is.
+	sugeno := fc.System.Method == "sugeno"
+	caps := fc.caps
 	for i := range caps {
-		caps[i] = make(map[string]float64)
+		for j := range caps[i] {
+			if sugeno {
+				caps[i][j] = math.Copysign(0, -1)
+			} else {
+				caps[i][j] = math.Inf(-1)
+			}
+		}
 	}
 
 	// For all the rules of the fuzzyController:
-	for _, r := range fc.Rules {
-		var res float64
-		// distinguish the conjunction method.
-		if r.Conjunction == "and" {
-			// if function is min: res init as 1.0
-			// if function is prod: res init as 1.0
-			res = 1.0 - fc.andFn(1.0, 0.0)
-			for i, v := range r.Antecedent {
-				// update the res with logical calculation.
-				res = fc.andFn(res, fc.input_mbr[i][v])
-			}
-		} else if r.Conjunction == "or" {
-			// if function is max: res init as 0.0
-			// if function is sum: res init as 0.0
-			// if function is probor: res init as 0.0
-			res = 1.0 - fc.orFn(1.0, 0.0)
-			for i, v := range r.Antecedent {
-				// update the res with logical calculation.
-				res = fc.orFn(res, fc.input_mbr[i][v])
-			}
-		} else {
-			// if unrecognizable option occurred.
-			return caps, fmt.Errorf(
-				`found in valid conjunction function: "and" or "or" expected, got %v `,
-				r.Conjunction,
-			)
+	fc.fired = 0
+	for n, r := range fc.Rules {
+		res, err := fc.strength(r)
+		if err != nil {
+			return caps, err
+		}
+		if res != 0 {
+			fc.fired++
 		}
 
 		// Now we have got the cap value for the current output membership function.
-		// The next thing to do is to store the value into a map so we can
-		// easily search it with hash key. Remeber that we could have multiple
-		// outputs, but the cap value should be identical for each outputs (with the
-		// same conjunction method, and probably different label).
-		if fc.System.Method == "sugeno" {
-			for i := range caps {
-				if _, ok := caps[i][r.Consequent[i]]; !ok {
-					caps[i][r.Consequent[i]] = res
-				} else {
-					caps[i][r.Consequent[i]] += res
-				}
-			}
-		} else {
-			for i := range caps {
-				if _, ok := caps[i][r.Consequent[i]]; !ok {
-					caps[i][r.Consequent[i]] = res
-				} else {
-					caps[i][r.Consequent[i]] = math.Max(caps[i][r.Consequent[i]], res)
-				}
+		// Remeber that we could have multiple outputs, but the cap value should be
+		// identical for each outputs (with the same conjunction method, and probably
+		// different label).
+		for i, j := range fc.conseq[n] {
+			if sugeno {
+				caps[i][j] += res
+			} else {
+				caps[i][j] = math.Max(caps[i][j], res)
 			}
 		}
 	}
 	return caps, nil
 }
+
+// Firing strength of a rule for the current inputs.
+func (fc *FuzzyController) strength(r rule) (float64, error) {
+	var res float64
+	// distinguish the conjunction method.
+	if r.Conjunction == "and" {
+		// if function is min: res init as 1.0
+		// if function is prod: res init as 1.0
+		res = 1.0 - fc.andFn(1.0, 0.0)
+		for i, v := range r.Antecedent {
+			// update the res with logical calculation.
+			res = fc.andFn(res, fc.input_mbr[i][v])
+		}
+	} else if r.Conjunction == "or" {
+		// if function is max: res init as 0.0
+		// if function is sum: res init as 0.0
+		// if function is probor: res init as 0.0
+		res = 1.0 - fc.orFn(1.0, 0.0)
+		for i, v := range r.Antecedent {
+			// update the res with logical calculation.
+			res = fc.orFn(res, fc.input_mbr[i][v])
+		}
+	} else {
+		// if unrecognizable option occurred.
+		return 0, fmt.Errorf(
+			`found in valid conjunction function: "and" or "or" expected, got %v `,
+			r.Conjunction,
+		)
+	}
+	return res, nil
+}
@@ -0,0 +1,210 @@
+package fuzzy
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:generate go run ../cmd/fuzzyschema -o ../model.schema.json
+
+// Additional keywords of the generated schema, keyed by
+// `<go type>` or `<go type>.<json field>`. The enumerations
+// are the ones checked by validate, so both can not drift apart.
+var schemaAnnotations = map[string]map[string]interface{}{
+	"FuzzyController.version": {
+		"description": "Version of the model format, older models are migrated when read.",
+		"minimum":     1,
+		"maximum":     ModelVersion,
+	},
+	"config.name": {
+		"description": "Name of the fuzzy model.",
+	},
+	"config.method": {
+		"description": "Inference method.",
+		"enum":        methods,
+	},
+	"config.numInputs": {
+		"description": "Number of inputs, inferred when absent.",
+		"minimum":     0,
+	},
+	"config.numOutputs": {
+		"description": "Number of outputs, inferred when absent.",
+		"minimum":     0,
+	},
+	"config.numRules": {
+		"description": "Number of rules, inferred when absent.",
+		"minimum":     0,
+	},
+	"config.andMethod": {
+		"description": "Operator of `and` rules.",
+		"enum":        andMethods,
+	},
+	"config.orMethod": {
+		"description": "Operator of `or` rules.",
+		"enum":        orMethods,
+	},
+	"config.impMethod": {
+		"description": "Implication method, required for mamdani.",
+		"enum":        impMethods,
+	},
+	"config.aggMethod": {
+		"description": "Aggregation method, required for mamdani.",
+		"enum":        aggMethods,
+	},
+	"config.defuzzMethod": {
+		"description": "Defuzzification method, wtaver/wtsum for sugeno and the others for mamdani.",
+		"enum":        defuzzMethods,
+	},
+	"member.name": {
+		"description": "Name of the input or output.",
+	},
+	"member.range": {
+		"description": "[min, max] of the values, inputs are clamped to it.",
+		"minItems":    2,
+		"maxItems":    2,
+	},
+	"member.mf": {
+		"description": "Membership functions of the input or output.",
+	},
+	"memberFunction.label": {
+		"description": "Label referred to by the rules.",
+	},
+	"memberFunction": {
+		"allOf": mfParamRules(),
+	},
+	"memberFunction.type": {
+		"description": "Type of the membership function, constant for sugeno outputs.",
+		"enum":        mfTypes(),
+	},
+	"memberFunction.params": {
+		"description": "Parameters of the membership function.",
+	},
+	"rule.antecedent": {
+		"description": "Input labels, one per input in order.",
+	},
+	"rule.consequent": {
+		"description": "Output labels, one per output in order.",
+	},
+	"rule.conjunction": {
+		"description": "How the antecedents are combined.",
+		"enum":        conjunctions,
+	},
+}
+
+// Generating the JSON Schema (draft-07) of the model files read
+// by NewFuzzyController, derived from the Go types of the model.
+//
+//	@Return: 1. - the indented schema document
+//			 2. - error occurred during the encoding
+func JSONSchema() ([]byte, error) {
+	schema := SchemaOf(reflect.TypeOf(FuzzyController{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "Fuzzy controller model"
+
+	b, err := json.MarshalIndent(schema, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Describing a Go type as a JSON Schema, following the json tags
+// of the struct fields. Fields tagged with `omitempty` are optional,
+// all the others are required. Pointers may be null, a time.Time
+// is a date-time string.
+func SchemaOf(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := SchemaOf(t.Elem())
+		if typ, ok := schema["type"].(string); ok {
+			schema["type"] = []string{typ, "null"}
+		}
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": SchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": SchemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if field.PkgPath != "" || tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if idx := strings.Index(tag, ","); idx >= 0 {
+				name, opts = tag[:idx], tag[idx+1:]
+			}
+			if name == "" {
+				name = field.Name
+			}
+			prop := SchemaOf(field.Type)
+			for k, v := range schemaAnnotations[t.Name()+"."+name] {
+				prop[k] = v
+			}
+			props[name] = prop
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+		for k, v := range schemaAnnotations[t.Name()] {
+			schema[k] = v
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// Sorted names of the membership function types.
+func mfTypes() []string {
+	var types []string
+	for t := range mfParamCount {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Every membership function type has a fixed number of parameters.
+func mfParamRules() []interface{} {
+	var rules []interface{}
+	for _, t := range mfTypes() {
+		n := mfParamCount[t]
+		rules = append(rules, map[string]interface{}{
+			"if": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{"const": t},
+				},
+			},
+			"then": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"params": map[string]interface{}{"minItems": n, "maxItems": n},
+				},
+			},
+		})
+	}
+	return rules
+}
@@ -5,48 +5,41 @@ import (
 	"math"
 )
 
-func aggr(
-	start float64,
-	end float64,
-	resolution int,
-	mfs []func(float64) float64,
-	cap []float64,
-	impMethod string,
-	aggMethod string) ([]float64, []float64, error) {
+var errResolution = errors.New("resolution should be an integer greater equals to 1")
+
+// The x values of an output curve, from start to end in
+// resolution steps. The points are accumulated, so the last one
+// may fall short of end.
+func grid(start float64, end float64, resolution int) ([]float64, error) {
 	if end <= start {
-		return nil, nil, errors.New("start value should be smaller than end value")
+		return nil, errors.New("start value should be smaller than end value")
 	}
 	if resolution <= 1 {
-		return nil, nil, errors.New("resolution should be an integer greater equals to 1")
+		return nil, errResolution
 	}
-	var (
-		x []float64
-		y []float64
-	)
 	resolution = int(math.Min(math.Max(float64(resolution), 1), 10000000))
 	step_length := (end - start) / float64(resolution)
+	x := make([]float64, 0, resolution+1)
 	for i := start; i <= end; i += step_length {
 		x = append(x, i)
 	}
-	var (
-		impFunc func(float64, float64) float64
-		aggFunc func(float64, float64) float64
-	)
-	if impMethod == "min" {
-		impFunc = math.Min
-	} else if impMethod == "max" {
-		impFunc = math.Max
-	}
-	if aggMethod == "min" {
-		aggFunc = math.Min
-	} else if aggMethod == "max" {
-		aggFunc = math.Max
-	}
-	y = make([]float64, len(x))
-	for i, fn := range mfs {
-		for idx, v := range x {
-			y[idx] = aggFunc(y[idx], impFunc(fn(v), cap[i]))
-		}
+	return x, nil
+}
+
+// Binary operators shared by the and/or methods, the implication
+// and the aggregation. Returns nil for unknown names.
+func normFunc(name string) func(float64, float64) float64 {
+	switch name {
+	case "min":
+		return math.Min
+	case "max":
+		return math.Max
+	case "prod":
+		return func(x float64, y float64) float64 { return x * y }
+	case "probor":
+		return func(x float64, y float64) float64 { return x + y - x*y }
+	case "sum":
+		return func(x float64, y float64) float64 { return x + y }
 	}
-	return x, y, nil
+	return nil
 }
@@ -0,0 +1,183 @@
+package fuzzy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Accepted values of the system config, they are also
+// published as enumerations by JSONSchema.
+var (
+	methods       = []string{"mamdani", "sugeno"}
+	andMethods    = []string{"min", "prod"}
+	orMethods     = []string{"max", "probor", "sum"}
+	impMethods    = []string{"min", "max", "prod"}
+	aggMethods    = []string{"max", "min", "sum", "probor"}
+	defuzzMethods = []string{"centroid", "bisector", "mom", "som", "lom", "wtaver", "wtsum"}
+	conjunctions  = []string{"and", "or"}
+)
+
+// Number of parameters of every membership function type.
+var mfParamCount = map[string]int{
+	"dsigmf":   4,
+	"sigmf":    2,
+	"gaussmf":  2,
+	"gauss2mf": 4,
+	"gbellmf":  3,
+	"pimf":     4,
+	"psigmf":   4,
+	"smf":      2,
+	"trapmf":   4,
+	"trimf":    3,
+	"zmf":      2,
+	"constant": 1,
+}
+
+// Checking the decoded model, so that mistakes in the model
+// file are reported at creation time instead of falling
+// through the switches during the calculation.
+func (fc *FuzzyController) validate() error {
+	sys := fc.System
+	if !contains(methods, sys.Method) {
+		return enumError("method", sys.Method, methods)
+	}
+	if !contains(andMethods, sys.Andmethod) {
+		return enumError("andMethod", sys.Andmethod, andMethods)
+	}
+	if !contains(orMethods, sys.Ormethod) {
+		return enumError("orMethod", sys.Ormethod, orMethods)
+	}
+	defuzz := sys.Defuzzmethod
+	if sys.Method == "mamdani" {
+		// Implication and aggregation are only used by mamdani.
+		if !contains(impMethods, sys.Impmethod) {
+			return enumError("impMethod", sys.Impmethod, impMethods)
+		}
+		if !contains(aggMethods, sys.Aggmethod) {
+			return enumError("aggMethod", sys.Aggmethod, aggMethods)
+		}
+		if !contains(defuzzMethods[:5], defuzz) {
+			return enumError("defuzzMethod", sys.Defuzzmethod, defuzzMethods[:5])
+		}
+	} else {
+		if sys.Impmethod != "" && !contains(impMethods, sys.Impmethod) {
+			return enumError("impMethod", sys.Impmethod, impMethods)
+		}
+		if sys.Aggmethod != "" && !contains(aggMethods, sys.Aggmethod) {
+			return enumError("aggMethod", sys.Aggmethod, aggMethods)
+		}
+		if !contains(defuzzMethods[5:], defuzz) {
+			return enumError("defuzzMethod", sys.Defuzzmethod, defuzzMethods[5:])
+		}
+	}
+
+	for i, mbr := range fc.Inputs {
+		if err := validateMember(mbr, false); err != nil {
+			return fmt.Errorf("input %v: %v", i, err)
+		}
+	}
+	for i, mbr := range fc.Outputs {
+		if err := validateMember(mbr, sys.Method == "sugeno"); err != nil {
+			return fmt.Errorf("output %v: %v", i, err)
+		}
+	}
+
+	for i, r := range fc.Rules {
+		if len(r.Antecedent) != len(fc.Inputs) {
+			return fmt.Errorf("rule %v: expect %v antecedents, got %v",
+				i, len(fc.Inputs), len(r.Antecedent))
+		}
+		if len(r.Consequent) != len(fc.Outputs) {
+			return fmt.Errorf("rule %v: expect %v consequents, got %v",
+				i, len(fc.Outputs), len(r.Consequent))
+		}
+		if !contains(conjunctions, r.Conjunction) {
+			return fmt.Errorf("rule %v: %v", i, enumError("conjunction", r.Conjunction, conjunctions))
+		}
+		for j, label := range r.Antecedent {
+			if !hasLabel(fc.Inputs[j], label) {
+				return fmt.Errorf("rule %v: input %q has no label %q", i, fc.Inputs[j].Name, label)
+			}
+		}
+		for j, label := range r.Consequent {
+			if !hasLabel(fc.Outputs[j], label) {
+				return fmt.Errorf("rule %v: output %q has no label %q", i, fc.Outputs[j].Name, label)
+			}
+		}
+	}
+	return nil
+}
+
+// Checking the range and the membership functions of an
+// input or output. Sugeno outputs take constants only.
+func validateMember(mbr member, sugeno bool) error {
+	if len(mbr.Range) != 2 || !(mbr.Range[0] < mbr.Range[1]) {
+		return fmt.Errorf("%q: range has to be [min, max] with min < max, got %v", mbr.Name, mbr.Range)
+	}
+	labels := make(map[string]bool)
+	for _, mf := range mbr.Mf {
+		if labels[mf.Label] {
+			return fmt.Errorf("%q: duplicated label %q", mbr.Name, mf.Label)
+		}
+		labels[mf.Label] = true
+
+		mfType := mf.Type
+		n, ok := mfParamCount[mfType]
+		if !ok {
+			return fmt.Errorf("%q: label %q has unknown type %q", mbr.Name, mf.Label, mf.Type)
+		}
+		if (mfType == "constant") != sugeno {
+			if sugeno {
+				return fmt.Errorf("%q: label %q has to be a constant for sugeno", mbr.Name, mf.Label)
+			}
+			return fmt.Errorf("%q: label %q can not be a constant", mbr.Name, mf.Label)
+		}
+		if len(mf.Params) != n {
+			return fmt.Errorf("%q: label %q expects %v parameters for %v, got %v",
+				mbr.Name, mf.Label, n, mfType, len(mf.Params))
+		}
+		if !paramsOrdered(mfType, mf.Params) {
+			return fmt.Errorf("%q: label %q has parameters out of order for %v: %v",
+				mbr.Name, mf.Label, mfType, mf.Params)
+		}
+	}
+	return nil
+}
+
+// The orderings the membership functions panic on.
+func paramsOrdered(mfType string, p []float64) bool {
+	switch mfType {
+	case "trimf":
+		return p[0] <= p[1] && p[1] <= p[2]
+	case "trapmf", "pimf":
+		return p[0] <= p[1] && p[1] <= p[2] && p[2] <= p[3]
+	case "smf", "zmf":
+		return p[0] <= p[1]
+	case "gauss2mf":
+		return p[0] <= p[2]
+	}
+	return true
+}
+
+func hasLabel(mbr member, label string) bool {
+	for _, mf := range mbr.Mf {
+		if mf.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func enumError(field string, value string, accepted []string) error {
+	return fmt.Errorf(`error by %v, expect one of "%v", got %q`,
+		field, strings.Join(accepted, `", "`), value)
+}
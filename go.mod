@@ -2,4 +2,9 @@ module fuzzy
 
 go 1.16
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/gorilla/mux v1.8.0
+	github.com/sirupsen/logrus v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
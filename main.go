@@ -1,94 +1,68 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"flag"
 	fuzzy "fuzzy/fuzzyMod"
-	"io/ioutil"
-	"log"
-	"net/http"
+	server "fuzzy/serverMod"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 )
 
-var fc fuzzy.FuzzyController
-
 func main() {
-	log.Println("Initializing fuzzy model..")
-	init_model, err := ioutil.ReadFile("./mamdaniModel.json")
-	if err != nil {
-		log.Fatal(err)
-	}
-	fc, err = fuzzy.NewFuzzyController(string(init_model))
-	if err != nil {
-		log.Fatal(err)
-	}
-	log.Println("Fuzzy model initialized")
-	r := newRouter()
-
-	server := &http.Server{
-		Addr:    "0.0.0.0:8808",
-		Handler: r,
-	}
-
-	go server.ListenAndServe()
-	log.Println("Server ready")
-	select {}
-}
-
-func newRouter() *mux.Router {
-	r := mux.NewRouter()
-	r.HandleFunc("/fuzzCon", newController).Methods("POST")
-	r.HandleFunc("/calculate", calculate)
-	return r
-}
-
-func newController(w http.ResponseWriter, r *http.Request) {
-	str, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Fatal(err)
+	cfg, err := server.LoadConfig(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		return
 	}
-
-	fc, err = fuzzy.NewFuzzyController(string(str))
 	if err != nil {
 		log.Fatal(err)
 	}
-}
-
-type Inputs struct {
-	InputX     []float64 `json:"input_x"`
-	Resolution []int     `json:"resolution"`
-}
+	level, _ := log.ParseLevel(cfg.LogLevel)
+	log.SetLevel(level)
 
-func calculate(w http.ResponseWriter, r *http.Request) {
-	info, err := ioutil.ReadAll(r.Body)
+	log.Info("Initializing fuzzy models..")
+	store, err := server.NewStore(cfg.ModelDir)
 	if err != nil {
 		log.Fatal(err)
 	}
-	var inputs Inputs
-	err = json.Unmarshal(info, &inputs)
-	if err != nil {
-		log.Fatal(err)
+	models := server.NewRegistry()
+	loaded, errs := store.LoadInto(models)
+	for _, err := range errs {
+		log.Errorf("model skipped: %v", err)
 	}
-
-	fc.SetInputs(inputs.InputX)
-
-	if fc.System.Method == "mamdani" {
-		err = fc.AggregateMamdani(inputs.Resolution)
+	models.SetStore(store)
+	if _, ok := models.Get(server.LegacyModel); !ok {
+		fc, err := fuzzy.LoadFuzzyController(cfg.SeedModel)
 		if err != nil {
 			log.Fatal(err)
 		}
-	} else if fc.System.Method == "sugeno" {
-		err = fc.AggregateSugeno()
-		if err != nil {
+		if _, err := models.Put(server.LegacyModel, fc); err != nil {
 			log.Fatal(err)
 		}
+		log.Infof("Model %q seeded from %v", server.LegacyModel, cfg.SeedModel)
+	}
+	log.Infof("Fuzzy models initialized: %v loaded from %v", len(loaded), cfg.ModelDir)
+
+	// SIGINT and SIGTERM stop the server, the requests in flight
+	// are finished first.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.ReloadInterval > 0 {
+		go models.Watch(ctx, time.Duration(cfg.ReloadInterval))
 	}
 
-	rst, err := fc.GetResult()
+	ln, err := net.Listen("tcp", cfg.Addr)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	w.Write([]byte(fmt.Sprintf("%v\n", rst)))
+	log.Infof("Server ready on %v", ln.Addr())
+	if err := server.Serve(ctx, cfg.HTTPServer(server.NewRouter(models)), ln, time.Duration(cfg.ShutdownTimeout)); err != nil {
+		log.Fatal(err)
+	}
+	log.Info("Server stopped")
 }
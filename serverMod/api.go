@@ -0,0 +1,366 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// Largest resolution of a mamdani output accepted by the API.
+const maxResolution = 1 << 20
+
+// Machine-readable codes of the errors of the /v1 API.
+const (
+	CodeInvalidBody      = "invalid_body"       // 400, the body is not what the route expects
+	CodeBodyTooLarge     = "body_too_large"     // 413
+	CodeUnsupportedType  = "unsupported_type"   // 415, the body is in no format the route reads
+	CodeInvalidName      = "invalid_name"       // 400, not a valid model name
+	CodeInvalidModel     = "invalid_model"      // 400, the model was refused
+	CodeInvalidInputs    = "invalid_inputs"     // 400, inputs or resolutions do not fit the model
+	CodeModelNotFound    = "model_not_found"    // 404
+	CodeNotFound         = "not_found"          // 404, no such route
+	CodeMethodNotAllowed = "method_not_allowed" // 405
+	CodeEvaluation       = "evaluation_failed"  // 500, the model failed on valid inputs
+	CodeInternal         = "internal"           // 500
+)
+
+// Body of the error responses of the /v1 API.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Summary of a model of the registry.
+type ModelSummary struct {
+	// Name of the model in the registry.
+	Name string `json:"name"`
+	// Name given by the model itself.
+	System  string   `json:"system"`
+	Method  string   `json:"method"`
+	Inputs  []string `json:"inputs"`
+	Outputs []string `json:"outputs"`
+	Rules   int      `json:"rules"`
+}
+
+type ModelList struct {
+	Models []ModelSummary `json:"models"`
+}
+
+// Body of the responses of /v1/models/{name}/calculate. An output
+// is null where it is undefined, e.g. when no rule fired.
+type CalculateResponse struct {
+	Model      string              `json:"model"`
+	Method     string              `json:"method"`
+	Inputs     map[string]float64  `json:"inputs"`
+	Outputs    map[string]*float64 `json:"outputs"`
+	Resolution []int               `json:"resolution,omitempty"`
+}
+
+// The /v1 routes, answering json:
+//
+//	GET    /v1/models                  - ModelList
+//	PUT    /v1/models/{name}           - add or replace a model, ModelSummary
+//	GET    /v1/models/{name}           - the model
+//	DELETE /v1/models/{name}           - remove a model
+//	POST   /v1/models/{name}/calculate - evaluate Inputs, CalculateResponse
+//	POST   /v1/models/{name}/batch     - evaluate json or csv rows, see batchV1
+//	GET    /v1/models/{name}/events    - EvaluationEvent stream, see streamEvents
+//	POST   /v1/models/{name}/explain   - trace the evaluation of Inputs, ExplainResponse
+//	POST   /v1/models/{name}/surface   - outputs over a grid, SurfaceRequest, SurfaceResponse
+//	GET    /v1/reload                  - ReloadStatus of the model files
+//
+// Errors answer ErrorResponse with a 4xx or 5xx status.
+func (s *server) routesV1(r *mux.Router) {
+	v1 := r.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/models", s.listModelsV1).Methods("GET")
+	v1.HandleFunc("/models/{name}", s.putModelV1).Methods("PUT")
+	v1.HandleFunc("/models/{name}", s.getModelV1).Methods("GET")
+	v1.HandleFunc("/models/{name}", s.deleteModelV1).Methods("DELETE")
+	v1.HandleFunc("/models/{name}/calculate", s.calculateV1).Methods("POST")
+	v1.HandleFunc("/models/{name}/batch", s.batchV1).Methods("POST")
+	v1.HandleFunc("/models/{name}/events", s.streamEvents).Methods("GET")
+	v1.HandleFunc("/models/{name}/explain", s.explainV1).Methods("POST")
+	v1.HandleFunc("/models/{name}/surface", s.surfaceV1).Methods("POST")
+	v1.HandleFunc("/reload", s.reloadStatus).Methods("GET")
+}
+
+func (s *server) reloadStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.reg.ReloadStatus())
+}
+
+func summary(name string, info fuzzy.ModelInfo) ModelSummary {
+	return ModelSummary{
+		Name:    name,
+		System:  info.Name,
+		Method:  info.Method,
+		Inputs:  info.Inputs,
+		Outputs: info.Outputs,
+		Rules:   info.Rules,
+	}
+}
+
+func (s *server) listModelsV1(w http.ResponseWriter, r *http.Request) {
+	list := ModelList{Models: []ModelSummary{}}
+	for _, name := range s.reg.Names() {
+		// The model may be gone meanwhile.
+		if h, ok := s.reg.Get(name); ok {
+			list.Models = append(list.Models, summary(name, h.Snapshot().Info()))
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *server) putModelV1(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !ValidModelName(name) {
+		writeError(w, http.StatusBadRequest, CodeInvalidName, "invalid model name %q, expect %v", name, modelName)
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	fc, err := fuzzy.ParseFuzzyController(string(body))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidModel, "%v", err)
+		return
+	}
+	created, err := s.reg.Put(name, fc)
+	if err != nil {
+		if errorStatus(err) == http.StatusInternalServerError {
+			internalError(w, r, err)
+		} else {
+			writeError(w, http.StatusBadRequest, CodeInvalidModel, "%v", err)
+		}
+		return
+	}
+	h, ok := s.reg.Get(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeModelNotFound, "model %q deleted meanwhile", name)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, summary(name, h.Snapshot().Info()))
+}
+
+func (s *server) getModelV1(w http.ResponseWriter, r *http.Request) {
+	h, ok := s.handleV1(w, r)
+	if !ok {
+		return
+	}
+	fc := h.Model()
+	str, err := fc.ToJSON()
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(str))
+}
+
+func (s *server) deleteModelV1(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	found, err := s.reg.Delete(name)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, CodeModelNotFound, "no model %q", name)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) calculateV1(w http.ResponseWriter, r *http.Request) {
+	h, ok := s.handleV1(w, r)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	var inputs Inputs
+	if !decodeV1(w, r, "inputs", &inputs) {
+		s.metrics.evalError(name, CodeInvalidBody)
+		return
+	}
+
+	snap := h.Snapshot()
+	info := snap.Info()
+	if msg := checkInputs(info, inputs); msg != "" {
+		s.metrics.evalError(name, CodeInvalidInputs)
+		writeError(w, http.StatusBadRequest, CodeInvalidInputs, "%v", msg)
+		return
+	}
+	out := make([]float64, len(info.Outputs))
+	var strengths []float64
+	if s.events.watched(name) {
+		strengths = make([]float64, info.Rules)
+	}
+	fired, err := snap.EvaluateRules(out, strengths, inputs.InputX, inputs.Resolution...)
+	if err != nil {
+		s.metrics.evalError(name, CodeEvaluation)
+		log.WithField("model", name).Errorf("evaluation failed: %v", err)
+		writeError(w, http.StatusInternalServerError, CodeEvaluation, "%v", err)
+		return
+	}
+	if fired == 0 {
+		s.metrics.noRuleFired(name)
+	}
+	if strengths != nil {
+		s.publish(name, info, inputs.InputX, out, strengths, fired)
+	}
+
+	resp := CalculateResponse{
+		Model:   name,
+		Method:  info.Method,
+		Inputs:  make(map[string]float64, len(info.Inputs)),
+		Outputs: namedOutputs(info, out),
+	}
+	for i, name := range info.Inputs {
+		resp.Inputs[name] = inputs.InputX[i]
+	}
+	if info.Method == "mamdani" {
+		resp.Resolution = inputs.Resolution
+		if len(resp.Resolution) == 0 {
+			resp.Resolution = make([]int, len(info.Outputs))
+			for i := range resp.Resolution {
+				resp.Resolution[i] = fuzzy.DefaultResolution
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// The outputs by name, nil where undefined.
+func namedOutputs(info fuzzy.ModelInfo, out []float64) map[string]*float64 {
+	named := make(map[string]*float64, len(info.Outputs))
+	for i, name := range info.Outputs {
+		if v := out[i]; !math.IsNaN(v) && !math.IsInf(v, 0) {
+			named[name] = &v
+		} else {
+			named[name] = nil
+		}
+	}
+	return named
+}
+
+// What is wrong with the inputs for the model, empty if they fit.
+func checkInputs(info fuzzy.ModelInfo, inputs Inputs) string {
+	if len(inputs.InputX) != len(info.Inputs) {
+		return fmt.Sprintf("expect %v input values %v, got %v", len(info.Inputs), info.Inputs, len(inputs.InputX))
+	}
+	return checkResolution(info, inputs.Resolution)
+}
+
+// What is wrong with the resolutions for the model, empty if they
+// fit or if there are none.
+func checkResolution(info fuzzy.ModelInfo, resolution []int) string {
+	if len(resolution) == 0 {
+		return ""
+	}
+	if len(resolution) != len(info.Outputs) {
+		return fmt.Sprintf("expect %v resolutions, got %v", len(info.Outputs), len(resolution))
+	}
+	for i, res := range resolution {
+		if res < 2 || res > maxResolution {
+			return fmt.Sprintf("resolution of output %q out of [2, %v], got %v", info.Outputs[i], maxResolution, res)
+		}
+	}
+	return ""
+}
+
+// The handle of the model named by the route, answering 404 if
+// there is no such model.
+func (s *server) handleV1(w http.ResponseWriter, r *http.Request) (*fuzzy.Handle, bool) {
+	name := mux.Vars(r)["name"]
+	h, ok := s.reg.Get(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeModelNotFound, "no model %q", name)
+	}
+	return h, ok
+}
+
+// Reading the request body up to maxBody, answering the error.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "error by reading the body: %v", err)
+		return nil, false
+	}
+	if len(body) > maxBody {
+		writeError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "body larger than %v bytes", maxBody)
+		return nil, false
+	}
+	return body, true
+}
+
+// Decoding the json request body into v, rejecting unknown fields,
+// answering the error.
+func decodeV1(w http.ResponseWriter, r *http.Request, what string, v interface{}) bool {
+	body, ok := readBody(w, r)
+	if !ok {
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidBody, "error by decoding the %v: %v", what, err)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Errorf("error by encoding the response: %v", err)
+		status = http.StatusInternalServerError
+		b, _ = json.Marshal(ErrorResponse{APIError{CodeInternal, err.Error()}})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, code string, format string, args ...interface{}) {
+	writeJSON(w, status, ErrorResponse{APIError{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	log.WithField("path", r.URL.Path).Errorf("internal error: %v", err)
+	writeError(w, http.StatusInternalServerError, CodeInternal, "%v", err)
+}
+
+// Unknown routes and methods, answered as json under /v1 and as
+// text elsewhere.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		writeError(w, http.StatusNotFound, CodeNotFound, "no route %v", r.URL.Path)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method %v not allowed for %v", r.Method, r.URL.Path)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
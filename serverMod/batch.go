@@ -0,0 +1,408 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Largest body of a batch. The body is read before the first
+// result is written, as HTTP/1 servers of Go do not read and write
+// at the same time.
+const maxBatchBody = 64 << 20
+
+// Rows of a csv result written at a time.
+const batchFlush = 256
+
+// A result of a json batch, in the order of the rows. Outputs are
+// null where undefined, as for CalculateResponse.
+type BatchRow struct {
+	// Index of the row in the batch, from 0.
+	Row     int                 `json:"row"`
+	Outputs map[string]*float64 `json:"outputs,omitempty"`
+	Error   *APIError           `json:"error,omitempty"`
+}
+
+// A row of a batch as read.
+type batchInput struct {
+	values []float64
+	// The input fields of a csv row, as sent.
+	fields []string
+	// Why the row can not be evaluated.
+	err *APIError
+}
+
+// Reading the rows of a batch and writing the results, in one
+// format.
+type batchCodec interface {
+	// The next row, io.EOF after the last one. Any other error is
+	// an error of the body.
+	read() (batchInput, error)
+	// Writing the status and the start of the response.
+	begin(w http.ResponseWriter)
+	write(row int, in batchInput, out []float64, rowErr *APIError) error
+	// Writing the end of the response.
+	finish() error
+}
+
+var errBatchTooLarge = fmt.Errorf("error by body size, larger than %v bytes", maxBatchBody)
+
+// Reader failing with errBatchTooLarge after n bytes.
+type batchBody struct {
+	r io.Reader
+	n int64
+}
+
+func (b *batchBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, errBatchTooLarge
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
+
+// Evaluating many input vectors, a json array of input vectors or
+// csv with a column per input named after it. The results are
+// streamed back in the same format as they are evaluated, in the
+// order of the rows; a row which can not be evaluated gets its own
+// error and the other rows are evaluated all the same.
+func (s *server) batchV1(w http.ResponseWriter, r *http.Request) {
+	h, ok := s.handleV1(w, r)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	snap := h.Snapshot()
+	info := snap.Info()
+	resolution, msg := parseResolution(r.URL.Query().Get("resolution"))
+	if msg == "" {
+		msg = checkResolution(info, resolution)
+	}
+	if msg != "" {
+		s.metrics.evalError(name, CodeInvalidInputs)
+		writeError(w, http.StatusBadRequest, CodeInvalidInputs, "%v", msg)
+		return
+	}
+
+	body := &batchBody{r: r.Body, n: maxBatchBody}
+	codec, apiErr := newBatchCodec(r.Header.Get("Content-Type"), body, w, info)
+	var rows []batchInput
+	for apiErr == nil {
+		in, err := codec.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			apiErr = bodyError(err)
+			break
+		}
+		rows = append(rows, in)
+	}
+	if apiErr != nil {
+		s.metrics.evalError(name, apiErr.Code)
+		writeError(w, batchStatus(apiErr), apiErr.Code, "%v", apiErr.Message)
+		return
+	}
+
+	// A client which leaves ends the evaluation of its rows.
+	ctx := r.Context()
+	fc := snap.Model()
+	inputs := make(chan []float64)
+	results, err := fc.EvaluateStream(ctx, inputs, resolution...)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	// A row which can not be evaluated is evaluated as nil, which
+	// fails at once.
+	go func() {
+		defer close(inputs)
+		for _, in := range rows {
+			select {
+			case inputs <- in.values:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	codec.begin(w)
+	var writeErr error
+	for res := range results {
+		in := rows[res.Index]
+		rowErr := in.err
+		if rowErr == nil && res.Err != nil {
+			rowErr = &APIError{Code: CodeEvaluation, Message: res.Err.Error()}
+		}
+		if rowErr != nil {
+			s.metrics.evalError(name, rowErr.Code)
+		}
+		if writeErr == nil {
+			writeErr = codec.write(res.Index, in, res.Outputs, rowErr)
+		}
+	}
+	if writeErr == nil && ctx.Err() == nil {
+		codec.finish()
+	}
+}
+
+// The resolutions of the query, comma separated.
+func parseResolution(query string) ([]int, string) {
+	if query == "" {
+		return nil, ""
+	}
+	var resolution []int
+	for _, field := range strings.Split(query, ",") {
+		res, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Sprintf("invalid resolution %q", field)
+		}
+		resolution = append(resolution, res)
+	}
+	return resolution, ""
+}
+
+func bodyError(err error) *APIError {
+	if errors.Is(err, errBatchTooLarge) {
+		return &APIError{Code: CodeBodyTooLarge, Message: err.Error()}
+	}
+	return &APIError{Code: CodeInvalidBody, Message: fmt.Sprintf("error by reading the body: %v", err)}
+}
+
+// Status of an error of the body of a batch.
+func batchStatus(apiErr *APIError) int {
+	switch apiErr.Code {
+	case CodeBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeUnsupportedType:
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+// The codec of the content type, json without one, which has read
+// the start of the body.
+func newBatchCodec(contentType string, body io.Reader, w http.ResponseWriter, info fuzzy.ModelInfo) (batchCodec, *APIError) {
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, &APIError{CodeInvalidBody, fmt.Sprintf("error by content type: %v", err)}
+		}
+	}
+	switch mediaType {
+	case "application/json":
+		return newJSONBatch(body, w, info)
+	case "text/csv":
+		return newCSVBatch(body, w, info)
+	}
+	return nil, &APIError{CodeUnsupportedType,
+		fmt.Sprintf("unsupported content type %q, expect application/json or text/csv", mediaType)}
+}
+
+// Batches of json arrays of input vectors, answered by a json array
+// of BatchRow.
+type jsonBatch struct {
+	dec  *json.Decoder
+	w    io.Writer
+	info fuzzy.ModelInfo
+}
+
+func newJSONBatch(body io.Reader, w io.Writer, info fuzzy.ModelInfo) (batchCodec, *APIError) {
+	dec := json.NewDecoder(body)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	if tok != json.Delim('[') {
+		return nil, &APIError{CodeInvalidBody, "expect a json array of input vectors"}
+	}
+	return &jsonBatch{dec: dec, w: w, info: info}, nil
+}
+
+func (b *jsonBatch) begin(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, "[")
+}
+
+func (b *jsonBatch) read() (batchInput, error) {
+	if !b.dec.More() {
+		// The end of the array, and of the body.
+		if tok, err := b.dec.Token(); err != nil || tok != json.Delim(']') {
+			if err == nil || err == io.EOF {
+				err = errors.New("unexpected end of the array")
+			}
+			return batchInput{}, err
+		}
+		if _, err := b.dec.Token(); err != io.EOF {
+			return batchInput{}, errors.New("data after the array")
+		}
+		return batchInput{}, io.EOF
+	}
+	var values []float64
+	if err := b.dec.Decode(&values); err != nil {
+		// A value of the wrong type is read all the same, the next
+		// row follows.
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return batchInput{err: &APIError{CodeInvalidInputs, fmt.Sprintf("expect an array of %v numbers: %v", len(b.info.Inputs), err)}}, nil
+		}
+		return batchInput{}, err
+	}
+	if len(values) != len(b.info.Inputs) {
+		return batchInput{err: &APIError{CodeInvalidInputs,
+			fmt.Sprintf("expect %v input values %v, got %v", len(b.info.Inputs), b.info.Inputs, len(values))}}, nil
+	}
+	return batchInput{values: values}, nil
+}
+
+func (b *jsonBatch) write(row int, in batchInput, out []float64, rowErr *APIError) error {
+	result := BatchRow{Row: row, Error: rowErr}
+	if rowErr == nil {
+		result.Outputs = namedOutputs(b.info, out)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if row == 0 {
+		sep = "\n"
+	}
+	_, err = io.WriteString(b.w, sep+string(data))
+	return err
+}
+
+func (b *jsonBatch) finish() error {
+	_, err := io.WriteString(b.w, "\n]\n")
+	return err
+}
+
+// Batches of csv with a header row, answered by csv with the
+// columns of the inputs, of the outputs and "error".
+type csvBatch struct {
+	r *csv.Reader
+	w *csv.Writer
+	// The column of every input.
+	columns []int
+	info    fuzzy.ModelInfo
+	record  []string
+}
+
+func newCSVBatch(body io.Reader, w io.Writer, info fuzzy.ModelInfo) (batchCodec, *APIError) {
+	r := csv.NewReader(body)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, &APIError{CodeInvalidBody, "expect a header row naming the inputs"}
+	}
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	index := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if _, ok := index[column]; ok {
+			return nil, &APIError{CodeInvalidBody, fmt.Sprintf("column %q twice", column)}
+		}
+		index[column] = i
+	}
+	b := &csvBatch{r: r, w: csv.NewWriter(w), info: info}
+	for _, input := range info.Inputs {
+		i, ok := index[input]
+		if !ok {
+			return nil, &APIError{CodeInvalidBody,
+				fmt.Sprintf("no column of input %q, expect the columns %v", input, info.Inputs)}
+		}
+		b.columns = append(b.columns, i)
+	}
+	b.record = make([]string, 0, len(info.Inputs)+len(info.Outputs)+1)
+	return b, nil
+}
+
+func (b *csvBatch) begin(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+	header := append(append(append([]string{}, b.info.Inputs...), b.info.Outputs...), "error")
+	b.w.Write(header)
+}
+
+func (b *csvBatch) read() (batchInput, error) {
+	record, err := b.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && !errors.Is(err, errBatchTooLarge) {
+		// Rows of the wrong length or quoting, the next row follows.
+		return batchInput{err: &APIError{CodeInvalidInputs, err.Error()}}, nil
+	}
+	if err != nil {
+		return batchInput{}, err
+	}
+	in := batchInput{
+		values: make([]float64, len(b.columns)),
+		fields: make([]string, len(b.columns)),
+	}
+	for i, column := range b.columns {
+		in.fields[i] = record[column]
+		v, err := strconv.ParseFloat(strings.TrimSpace(record[column]), 64)
+		if err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			err = errors.New("not a finite number")
+		}
+		if err != nil {
+			in.values = nil
+			in.err = &APIError{CodeInvalidInputs, fmt.Sprintf("column %q: invalid value %q", b.info.Inputs[i], record[column])}
+			break
+		}
+		in.values[i] = v
+	}
+	return in, nil
+}
+
+func (b *csvBatch) write(row int, in batchInput, out []float64, rowErr *APIError) error {
+	record := b.record[:0]
+	for i := range b.info.Inputs {
+		field := ""
+		if i < len(in.fields) {
+			field = in.fields[i]
+		}
+		record = append(record, field)
+	}
+	for i := range b.info.Outputs {
+		field := ""
+		if rowErr == nil && !math.IsNaN(out[i]) && !math.IsInf(out[i], 0) {
+			field = strconv.FormatFloat(out[i], 'g', -1, 64)
+		}
+		record = append(record, field)
+	}
+	field := ""
+	if rowErr != nil {
+		field = rowErr.Code + ": " + rowErr.Message
+	}
+	if err := b.w.Write(append(record, field)); err != nil {
+		return err
+	}
+	// Streaming a chunk at a time.
+	if row%batchFlush == batchFlush-1 {
+		b.w.Flush()
+	}
+	return b.w.Error()
+}
+
+func (b *csvBatch) finish() error {
+	b.w.Flush()
+	return b.w.Error()
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// A time.Duration written as "5s", "1m30s", ... in config files
+// and environment variables.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Settings of the server. They are taken from the defaults, a
+// config file, the environment and the flags, each overriding
+// the former.
+type Config struct {
+	// Address to listen on, host:port.
+	Addr string `json:"addr" yaml:"addr" toml:"addr"`
+	// Directory of the model store.
+	ModelDir string `json:"modelDir" yaml:"modelDir" toml:"modelDir"`
+	// Model file the store is seeded with if it has no default model.
+	SeedModel string `json:"seedModel" yaml:"seedModel" toml:"seedModel"`
+	// How often the model directory is checked for changed files,
+	// 0 to never.
+	ReloadInterval Duration `json:"reloadInterval" yaml:"reloadInterval" toml:"reloadInterval"`
+	// Timeouts of reading a request and of writing a response.
+	ReadTimeout  Duration `json:"readTimeout" yaml:"readTimeout" toml:"readTimeout"`
+	WriteTimeout Duration `json:"writeTimeout" yaml:"writeTimeout" toml:"writeTimeout"`
+	// How long the requests in flight may take to finish when the
+	// server shuts down.
+	ShutdownTimeout Duration `json:"shutdownTimeout" yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+	// One of panic, fatal, error, warn, info, debug and trace.
+	LogLevel string `json:"logLevel" yaml:"logLevel" toml:"logLevel"`
+}
+
+// The settings without config file, environment and flags.
+func DefaultConfig() Config {
+	return Config{
+		Addr:            "0.0.0.0:8808",
+		ModelDir:        "./models",
+		SeedModel:       "./mamdaniModel.json",
+		ReloadInterval:  Duration(2 * time.Second),
+		ReadTimeout:     Duration(10 * time.Second),
+		WriteTimeout:    Duration(30 * time.Second),
+		ShutdownTimeout: Duration(30 * time.Second),
+		LogLevel:        "info",
+	}
+}
+
+// Prefix of the environment variables of the settings, e.g.
+// FUZZY_ADDR or FUZZY_READ_TIMEOUT. FUZZY_CONFIG names the config
+// file.
+const envPrefix = "FUZZY_"
+
+// The settings by flag name, the environment variable is the
+// upper case flag name with '-' replaced by '_' after envPrefix.
+func (cfg *Config) settings() []struct {
+	name, usage string
+	value       flag.Value
+} {
+	return []struct {
+		name, usage string
+		value       flag.Value
+	}{
+		{"addr", "`host:port` to listen on", (*stringValue)(&cfg.Addr)},
+		{"model-dir", "`directory` of the model store", (*stringValue)(&cfg.ModelDir)},
+		{"seed-model", "model `file` the store is seeded with if it has no default model", (*stringValue)(&cfg.SeedModel)},
+		{"reload-interval", "`interval` of checking the model directory, 0 to never", &cfg.ReloadInterval},
+		{"read-timeout", "`timeout` of reading a request", &cfg.ReadTimeout},
+		{"write-timeout", "`timeout` of writing a response", &cfg.WriteTimeout},
+		{"shutdown-timeout", "`timeout` of the requests in flight at shutdown", &cfg.ShutdownTimeout},
+		{"log-level", "`level` of the log: panic, fatal, error, warn, info, debug or trace", (*stringValue)(&cfg.LogLevel)},
+	}
+}
+
+type stringValue string
+
+func (s *stringValue) String() string     { return string(*s) }
+func (s *stringValue) Set(v string) error { *s = stringValue(v); return nil }
+
+func (d *Duration) String() string     { return time.Duration(*d).String() }
+func (d *Duration) Set(v string) error { return d.UnmarshalText([]byte(v)) }
+
+// Reading the settings.
+//
+//	@Params: args - the command line arguments, without the
+//			 program name. -config names the config file, json,
+//			 yaml or toml by its extension.
+//
+//			 getenv - the environment, os.Getenv.
+//
+//	@Return: 1. - the settings
+//			 2. - error by the flags, the config file or the
+//			 environment, flag.ErrHelp for -h
+func LoadConfig(args []string, getenv func(string) string) (Config, error) {
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("fuzzy", flag.ContinueOnError)
+	configFile := fs.String("config", getenv(envPrefix+"CONFIG"), "config `file`, json, yaml or toml (env "+envPrefix+"CONFIG)")
+	// The flags are parsed into a copy, they are applied last.
+	flags := cfg
+	for _, s := range flags.settings() {
+		fs.Var(s.value, s.name, fmt.Sprintf("%v (env %v)", s.usage, envName(s.name)))
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	if *configFile != "" {
+		if err := cfg.readFile(*configFile); err != nil {
+			return cfg, err
+		}
+	}
+	for _, s := range cfg.settings() {
+		if v := getenv(envName(s.name)); v != "" {
+			if err := s.value.Set(v); err != nil {
+				return cfg, fmt.Errorf("error by %v=%q: %v", envName(s.name), v, err)
+			}
+		}
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range cfg.settings() {
+			if s.name == f.Name && err == nil {
+				err = s.value.Set(f.Value.String())
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.check()
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// Reading a config file, unknown settings are refused.
+func (cfg *Config) readFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(content), cfg)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown settings %v", meta.Undecoded())
+		}
+	default:
+		return fmt.Errorf("%v: unknown config format, expect .json, .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %v", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) check() error {
+	if _, err := log.ParseLevel(cfg.LogLevel); err != nil {
+		return err
+	}
+	if cfg.Addr == "" || cfg.ModelDir == "" {
+		return errors.New("error by settings, addr and model-dir must not be empty")
+	}
+	for _, d := range []Duration{cfg.ReloadInterval, cfg.ReadTimeout, cfg.WriteTimeout, cfg.ShutdownTimeout} {
+		if d < 0 {
+			return fmt.Errorf("error by settings, negative duration %v", time.Duration(d))
+		}
+	}
+	return nil
+}
+
+// The http.Server of the settings.
+func (cfg Config) HTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      handler,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+	}
+}
+
+// Serving on ln until ctx is done, then shutting down gracefully:
+// no new connections are accepted and the requests in flight
+// finish, for at most shutdownTimeout.
+//
+//	@Return: error of serving, or of the shutdown if the requests
+//			 in flight did not finish in time
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdown)
+	if served := <-served; served != http.ErrServerClosed && err == nil {
+		err = served
+	}
+	return err
+}
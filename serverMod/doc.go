@@ -0,0 +1,24 @@
+// Package server serves named fuzzy models over HTTP.
+//
+// The routes are described by the OpenAPI document GET /openapi.json
+// (OpenAPI in Go), browsable as GET /docs. The json API lives under
+// /v1: models are uploaded in any supported format with PUT
+// /v1/models/{name} and evaluated with POST
+// /v1/models/{name}/calculate, or many inputs at once with /batch,
+// traced with /explain, sampled over a grid with /surface and watched
+// as Server-Sent Events with GET /v1/models/{name}/events. Errors
+// answer {"error": {"code": ..., "message": ...}} with the Code*
+// constants. The routes without /v1 and the legacy /fuzzCon and
+// /calculate, which work on the model LegacyModel, answer errors as
+// plain text.
+//
+// A Registry keeps the models, evaluated concurrently through a
+// fuzzy.Handle each. With a Store, one <name>.json file per model, the
+// models survive restarts, and Watch reloads the files changed by
+// others. GET /metrics answers metrics in the Prometheus text format.
+//
+// LoadConfig reads the Config of the server from the defaults, the
+// environment (FUZZY_ plus the flag name in upper case), a config
+// file and the flags, and Serve shuts the server down gracefully
+// once its context is done.
+package server
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Number of the strongest rules an event lists.
+	topRules = 5
+	// Events a subscriber may lag behind, further events are
+	// dropped for it until it catches up.
+	subscriberBuffer = 64
+	// Interval of the comments keeping idle streams open through
+	// proxies.
+	keepAlive = 15 * time.Second
+	// Delay of the reconnects of the browsers, e.g. after the write
+	// timeout of the server ended a stream.
+	reconnect = time.Second
+)
+
+// An evaluation of a model, pushed to the subscribers of the model.
+type EvaluationEvent struct {
+	Model   string              `json:"model"`
+	Time    time.Time           `json:"time"`
+	Inputs  map[string]float64  `json:"inputs"`
+	Outputs map[string]*float64 `json:"outputs"`
+	// Number of rules which fired.
+	Fired int `json:"fired"`
+	// The strongest firing rules, strongest first.
+	Rules []RuleFiring `json:"rules"`
+}
+
+type RuleFiring struct {
+	// Index of the rule in the model.
+	Rule     int     `json:"rule"`
+	Strength float64 `json:"strength"`
+}
+
+// Subscribers of the evaluations, by model.
+type events struct {
+	mu   sync.RWMutex
+	subs map[string]map[*subscriber]struct{}
+	// Closed by the shutdown of the http.Server, by server. The
+	// contexts of the requests stay alive while the other requests
+	// drain, the streams would hold up the shutdown.
+	shutdown map[*http.Server]chan struct{}
+}
+
+type subscriber struct {
+	events  chan []byte
+	dropped uint64 // atomic, events missed since the last notice
+}
+
+func (ev *events) subscribe(model string) *subscriber {
+	sub := &subscriber{events: make(chan []byte, subscriberBuffer)}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	if ev.subs == nil {
+		ev.subs = make(map[string]map[*subscriber]struct{})
+	}
+	if ev.subs[model] == nil {
+		ev.subs[model] = make(map[*subscriber]struct{})
+	}
+	ev.subs[model][sub] = struct{}{}
+	return sub
+}
+
+func (ev *events) unsubscribe(model string, sub *subscriber) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	delete(ev.subs[model], sub)
+	if len(ev.subs[model]) == 0 {
+		delete(ev.subs, model)
+	}
+}
+
+// Channel closed when the http.Server serving r shuts down, nil if
+// r is not served by an http.Server.
+func (ev *events) shuttingDown(r *http.Request) <-chan struct{} {
+	srv, _ := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if srv == nil {
+		return nil
+	}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	if done, ok := ev.shutdown[srv]; ok {
+		return done
+	}
+	if ev.shutdown == nil {
+		ev.shutdown = make(map[*http.Server]chan struct{})
+	}
+	done := make(chan struct{})
+	ev.shutdown[srv] = done
+	srv.RegisterOnShutdown(func() {
+		ev.mu.Lock()
+		defer ev.mu.Unlock()
+		delete(ev.shutdown, srv)
+		close(done)
+	})
+	return done
+}
+
+// Whether the model has subscribers, so that its evaluations are
+// worth an event.
+func (ev *events) watched(model string) bool {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return len(ev.subs[model]) > 0
+}
+
+// Handing an event to the subscribers of the model without ever
+// waiting for them: a subscriber with a full buffer misses it.
+//
+//	@Return: the number of subscribers which missed the event
+func (ev *events) publish(model string, data []byte) int {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	dropped := 0
+	for sub := range ev.subs[model] {
+		select {
+		case sub.events <- data:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Publishing an evaluation of a model.
+//
+//	@Params: strengths - the firing strengths of the rules.
+func (s *server) publish(name string, info fuzzy.ModelInfo, inputs, out, strengths []float64, fired int) {
+	event := EvaluationEvent{
+		Model:   name,
+		Time:    time.Now().UTC(),
+		Inputs:  make(map[string]float64, len(info.Inputs)),
+		Outputs: namedOutputs(info, out),
+		Fired:   fired,
+		Rules:   strongestRules(strengths, topRules),
+	}
+	for i, input := range info.Inputs {
+		event.Inputs[input] = inputs[i]
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.WithField("model", name).Errorf("error by encoding the event: %v", err)
+		return
+	}
+	if dropped := s.events.publish(name, data); dropped > 0 {
+		s.metrics.eventsDropped(name, dropped)
+	}
+}
+
+// The n rules of the largest non-zero strengths, strongest first
+// and in model order among equals.
+func strongestRules(strengths []float64, n int) []RuleFiring {
+	rules := []RuleFiring{}
+	for k, v := range strengths {
+		if v > 0 {
+			rules = append(rules, RuleFiring{Rule: k, Strength: v})
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Strength > rules[j].Strength })
+	if len(rules) > n {
+		rules = rules[:n]
+	}
+	return rules
+}
+
+// Streaming the evaluations of a model as Server-Sent Events until
+// the client leaves or the server shuts down. Every evaluation is
+// a message with an EvaluationEvent as data; a "dropped" event
+// tells how many evaluations the client missed because it did not
+// keep up.
+func (s *server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.handleV1(w, r); !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		internalError(w, r, errors.New("error by streaming, the connection can not flush"))
+		return
+	}
+	name := mux.Vars(r)["name"]
+	shutdown := s.events.shuttingDown(r)
+	sub := s.events.subscribe(name)
+	defer s.events.unsubscribe(name, sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", reconnect/time.Millisecond)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shutdown:
+			return
+		case <-ticker.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		case data := <-sub.events:
+			if n := atomic.SwapUint64(&sub.dropped, 0); n > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", n)
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"math"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Values of an axis of a surface without points.
+	defaultSurfacePoints = 21
+	// Most values of an axis, and of a whole surface.
+	maxSurfaceAxis   = 1001
+	maxSurfacePoints = 1 << 16
+	// Most points of the mamdani output curves of a whole surface,
+	// its points times the resolutions.
+	maxSurfaceCurves = 1 << 26
+)
+
+// Body of the responses of /v1/models/{name}/explain, the trace of
+// an evaluation, see fuzzy.Explanation.
+type ExplainResponse struct {
+	Model   string              `json:"model"`
+	Method  string              `json:"method"`
+	Inputs  []fuzzy.InputTrace  `json:"inputs"`
+	Rules   []fuzzy.RuleTrace   `json:"rules"`
+	Outputs []fuzzy.OutputTrace `json:"outputs"`
+}
+
+// Request body of the surfaces: the outputs over a grid of one or
+// two inputs, the other inputs fixed.
+type SurfaceRequest struct {
+	Axes []SurfaceAxis `json:"axes"`
+	// Values of the other inputs by name, the middle of their range
+	// where absent.
+	Inputs     map[string]float64 `json:"inputs,omitempty"`
+	Resolution []int              `json:"resolution,omitempty"`
+}
+
+type SurfaceAxis struct {
+	Input string `json:"input"`
+	// Number of values, evenly spaced from min to max, 21 where
+	// absent.
+	Points int `json:"points,omitempty"`
+	// Bounds of the values, the range of the input where absent.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Body of the responses of /v1/models/{name}/surface.
+type SurfaceResponse struct {
+	Model  string       `json:"model"`
+	Method string       `json:"method"`
+	Axes   []AxisValues `json:"axes"`
+	// Values of the other inputs.
+	Inputs map[string]float64 `json:"inputs"`
+	// The outputs by name on the grid, row-major: the values of the
+	// last axis follow each other. Null where undefined.
+	Outputs map[string][]*float64 `json:"outputs"`
+}
+
+type AxisValues struct {
+	Input  string    `json:"input"`
+	Values []float64 `json:"values"`
+}
+
+func (s *server) explainV1(w http.ResponseWriter, r *http.Request) {
+	h, ok := s.handleV1(w, r)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	var inputs Inputs
+	if !decodeV1(w, r, "inputs", &inputs) {
+		s.metrics.evalError(name, CodeInvalidBody)
+		return
+	}
+	snap := h.Snapshot()
+	info := snap.Info()
+	if msg := checkInputs(info, inputs); msg != "" {
+		s.metrics.evalError(name, CodeInvalidInputs)
+		writeError(w, http.StatusBadRequest, CodeInvalidInputs, "%v", msg)
+		return
+	}
+	ex, err := snap.Explain(inputs.InputX, inputs.Resolution...)
+	if err != nil {
+		s.metrics.evalError(name, CodeEvaluation)
+		log.WithField("model", name).Errorf("evaluation failed: %v", err)
+		writeError(w, http.StatusInternalServerError, CodeEvaluation, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ExplainResponse{
+		Model:   name,
+		Method:  info.Method,
+		Inputs:  ex.Inputs,
+		Rules:   ex.Rules,
+		Outputs: ex.Outputs,
+	})
+}
+
+func (s *server) surfaceV1(w http.ResponseWriter, r *http.Request) {
+	h, ok := s.handleV1(w, r)
+	if !ok {
+		return
+	}
+	name := mux.Vars(r)["name"]
+	var req SurfaceRequest
+	if !decodeV1(w, r, "surface", &req) {
+		s.metrics.evalError(name, CodeInvalidBody)
+		return
+	}
+	snap := h.Snapshot()
+	info := snap.Info()
+	fc := snap.Model()
+	msg := checkResolution(info, req.Resolution)
+	var (
+		resp SurfaceResponse
+		rows [][]float64
+	)
+	if msg == "" {
+		resp, rows, msg = surfaceGrid(&fc, req)
+	}
+	if msg != "" {
+		s.metrics.evalError(name, CodeInvalidInputs)
+		writeError(w, http.StatusBadRequest, CodeInvalidInputs, "%v", msg)
+		return
+	}
+
+	// Evaluating on all cores until the client is gone.
+	ctx := r.Context()
+	inputs := make(chan []float64)
+	results, err := fc.EvaluateStream(ctx, inputs, req.Resolution...)
+	if err != nil {
+		s.metrics.evalError(name, CodeEvaluation)
+		log.WithField("model", name).Errorf("evaluation failed: %v", err)
+		writeError(w, http.StatusInternalServerError, CodeEvaluation, "%v", err)
+		return
+	}
+	go func() {
+		defer close(inputs)
+		for _, row := range rows {
+			select {
+			case inputs <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	outputs := make([][]float64, len(rows))
+	var rowErrs []fuzzy.RowError
+	for res := range results {
+		if res.Err != nil {
+			rowErrs = append(rowErrs, fuzzy.RowError{Row: res.Index, Err: res.Err})
+			continue
+		}
+		outputs[res.Index] = res.Outputs
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if len(rowErrs) > 0 {
+		// The failed points stay null.
+		s.metrics.evalError(name, CodeEvaluation)
+		log.WithField("model", name).Errorf("evaluation of the surface failed: %v", &fuzzy.BatchError{Rows: rowErrs})
+	}
+	resp.Model = name
+	resp.Method = info.Method
+	resp.Outputs = make(map[string][]*float64, len(info.Outputs))
+	for i, output := range info.Outputs {
+		values := make([]*float64, len(outputs))
+		for k, out := range outputs {
+			if out != nil && !math.IsNaN(out[i]) && !math.IsInf(out[i], 0) {
+				values[k] = &out[i]
+			}
+		}
+		resp.Outputs[output] = values
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// The axes and the fixed inputs of a surface, and the input vectors
+// of its points, row-major. The resolutions of the request are
+// checked before.
+//
+//	@Return: 1. - the response without model and outputs
+//			 2. - the input vectors
+//			 3. - what is wrong with the request, empty if nothing
+func surfaceGrid(fc *fuzzy.FuzzyController, req SurfaceRequest) (SurfaceResponse, [][]float64, string) {
+	resp := SurfaceResponse{Inputs: map[string]float64{}}
+	index := make(map[string]int, len(fc.Inputs))
+	for i, in := range fc.Inputs {
+		index[in.Name] = i
+	}
+	if len(req.Axes) != 1 && len(req.Axes) != 2 {
+		return resp, nil, fmt.Sprintf("expect 1 or 2 axes, got %v", len(req.Axes))
+	}
+
+	// The axis of every input, -1 for the fixed ones.
+	axisOf := make([]int, len(fc.Inputs))
+	for i := range axisOf {
+		axisOf[i] = -1
+	}
+	total := 1
+	for a, axis := range req.Axes {
+		i, ok := index[axis.Input]
+		if !ok {
+			return resp, nil, fmt.Sprintf("axis %v: no input %q", a, axis.Input)
+		}
+		if axisOf[i] >= 0 {
+			return resp, nil, fmt.Sprintf("axis %v: input %q twice", a, axis.Input)
+		}
+		axisOf[i] = a
+		points := axis.Points
+		if points == 0 {
+			points = defaultSurfacePoints
+		}
+		if points < 2 || points > maxSurfaceAxis {
+			return resp, nil, fmt.Sprintf("axis %v: points out of [2, %v], got %v", a, maxSurfaceAxis, points)
+		}
+		lo, hi := fc.Inputs[i].Range[0], fc.Inputs[i].Range[1]
+		if axis.Min != nil {
+			lo = *axis.Min
+		}
+		if axis.Max != nil {
+			hi = *axis.Max
+		}
+		if !(lo < hi) {
+			return resp, nil, fmt.Sprintf("axis %v: expect min < max, got %v and %v", a, lo, hi)
+		}
+		values := make([]float64, points)
+		for k := range values {
+			values[k] = lo + (hi-lo)*float64(k)/float64(points-1)
+		}
+		values[points-1] = hi
+		resp.Axes = append(resp.Axes, AxisValues{Input: axis.Input, Values: values})
+		total *= points
+	}
+	if total > maxSurfacePoints {
+		return resp, nil, fmt.Sprintf("expect at most %v points, got %v", maxSurfacePoints, total)
+	}
+	if fc.System.Method == "mamdani" {
+		// Every point builds a curve of resolution points per output.
+		curve := 0
+		for i := range fc.Outputs {
+			if len(req.Resolution) == len(fc.Outputs) {
+				curve += req.Resolution[i]
+			} else {
+				curve += fuzzy.DefaultResolution
+			}
+		}
+		if total*curve > maxSurfaceCurves {
+			return resp, nil, fmt.Sprintf("expect at most %v points times resolution, got %v points of resolution %v",
+				maxSurfaceCurves, total, curve)
+		}
+	}
+
+	base := make([]float64, len(fc.Inputs))
+	for name, v := range req.Inputs {
+		i, ok := index[name]
+		if !ok {
+			return resp, nil, fmt.Sprintf("no input %q", name)
+		}
+		if axisOf[i] >= 0 {
+			return resp, nil, fmt.Sprintf("input %q is an axis", name)
+		}
+		base[i] = v
+	}
+	for i, in := range fc.Inputs {
+		if axisOf[i] >= 0 {
+			continue
+		}
+		if _, ok := req.Inputs[in.Name]; !ok {
+			base[i] = (in.Range[0] + in.Range[1]) / 2
+		}
+		resp.Inputs[in.Name] = base[i]
+	}
+
+	rows := make([][]float64, 0, total)
+	inner := []float64{0}
+	if len(resp.Axes) == 2 {
+		inner = resp.Axes[1].Values
+	}
+	for _, v0 := range resp.Axes[0].Values {
+		for _, v1 := range inner {
+			row := append([]float64(nil), base...)
+			for i, a := range axisOf {
+				switch a {
+				case 0:
+					row[i] = v0
+				case 1:
+					row[i] = v1
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return resp, rows, ""
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Upper bounds of the buckets of the latency histograms, seconds.
+var latencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counters of the server, written in the Prometheus text format.
+type metrics struct {
+	mu         sync.Mutex
+	requests   map[requestKey]uint64
+	latencies  map[latencyKey]*histogram
+	evalErrors map[[2]string]uint64 // model, type
+	noRule     map[string]uint64    // model
+	dropped    map[string]uint64    // model
+}
+
+type requestKey struct {
+	route, method, model string
+	code                 int
+}
+
+type latencyKey struct {
+	route, model string
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, the last for +Inf
+	sum    float64
+	count  uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:   make(map[requestKey]uint64),
+		latencies:  make(map[latencyKey]*histogram),
+		evalErrors: make(map[[2]string]uint64),
+		noRule:     make(map[string]uint64),
+		dropped:    make(map[string]uint64),
+	}
+}
+
+func (m *metrics) request(route, method, model string, code int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{route, method, model, code}]++
+	h, ok := m.latencies[latencyKey{route, model}]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets)+1)}
+		m.latencies[latencyKey{route, model}] = h
+	}
+	seconds := elapsed.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// An evaluation failed, errType is the error code of the API.
+func (m *metrics) evalError(model, errType string) {
+	m.mu.Lock()
+	m.evalErrors[[2]string{model, errType}]++
+	m.mu.Unlock()
+}
+
+// An evaluation succeeded without any rule firing.
+func (m *metrics) noRuleFired(model string) {
+	m.mu.Lock()
+	m.noRule[model]++
+	m.mu.Unlock()
+}
+
+// Events of a model missed by n slow subscribers.
+func (m *metrics) eventsDropped(model string, n int) {
+	m.mu.Lock()
+	m.dropped[model] += uint64(n)
+	m.mu.Unlock()
+}
+
+// Writing the metrics, the series of a metric sorted by labels.
+func (m *metrics) write(w io.Writer, reg *Registry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The series of the last metric, written by flush.
+	var lines []string
+	flush := func() {
+		sort.Strings(lines)
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+		lines = lines[:0]
+	}
+	header := func(name, kind, help string) {
+		flush()
+		fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+	}
+
+	header("fuzzy_http_requests_total", "counter", "HTTP requests by route, method, model and status code.")
+	for k, n := range m.requests {
+		lines = append(lines, fmt.Sprintf("fuzzy_http_requests_total%v %v",
+			labels("code", strconv.Itoa(k.code), "method", k.method, "model", k.model, "route", k.route), n))
+	}
+
+	header("fuzzy_http_request_duration_seconds", "histogram", "Latency of the HTTP requests by route and model.")
+	// The buckets of a series stay in the order of their bounds,
+	// the series are sorted by their keys instead of their lines.
+	keys := make([]latencyKey, 0, len(m.latencies))
+	for k := range m.latencies {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].model < keys[j].model
+	})
+	for _, k := range keys {
+		h := m.latencies[k]
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = strconv.FormatFloat(latencyBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "fuzzy_http_request_duration_seconds_bucket%v %v\n",
+				labels("le", le, "model", k.model, "route", k.route), cumulative)
+		}
+		fmt.Fprintf(w, "fuzzy_http_request_duration_seconds_sum%v %v\n",
+			labels("model", k.model, "route", k.route), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "fuzzy_http_request_duration_seconds_count%v %v\n",
+			labels("model", k.model, "route", k.route), h.count)
+	}
+
+	header("fuzzy_evaluation_errors_total", "counter", "Failed evaluations by model and error code.")
+	for k, n := range m.evalErrors {
+		lines = append(lines, fmt.Sprintf("fuzzy_evaluation_errors_total%v %v", labels("model", k[0], "type", k[1]), n))
+	}
+
+	header("fuzzy_no_rule_fired_total", "counter", "Evaluations without any rule firing, with undefined outputs, by model.")
+	for model, n := range m.noRule {
+		lines = append(lines, fmt.Sprintf("fuzzy_no_rule_fired_total%v %v", labels("model", model), n))
+	}
+
+	header("fuzzy_events_dropped_total", "counter", "Evaluation events missed by slow subscribers, by model.")
+	for model, n := range m.dropped {
+		lines = append(lines, fmt.Sprintf("fuzzy_events_dropped_total%v %v", labels("model", model), n))
+	}
+
+	status := reg.ReloadStatus()
+	header("fuzzy_model_reloads_total", "counter", "Reloads of model files from the store by result.")
+	lines = append(lines,
+		fmt.Sprintf("fuzzy_model_reloads_total%v %v", labels("result", "ok"), status.Reloads),
+		fmt.Sprintf("fuzzy_model_reloads_total%v %v", labels("result", "failed"), status.Failures))
+
+	header("fuzzy_models", "gauge", "Models served.")
+	lines = append(lines, fmt.Sprintf("fuzzy_models %v", len(reg.Names())))
+	flush()
+}
+
+// Label set of a series, from name and value pairs.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Response writer recording the status code.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flushing for the event streams.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Middleware counting the requests and their latencies.
+func (s *server) measure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		route := "unmatched"
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		s.metrics.request(route, r.Method, s.modelLabel(r, route), sw.status, time.Since(start))
+	})
+}
+
+// The model of a request as label, empty for requests without
+// model and for unknown models, which keeps clients from creating
+// series at will.
+func (s *server) modelLabel(r *http.Request, route string) string {
+	name, ok := mux.Vars(r)["name"]
+	if !ok && (route == "/fuzzCon" || route == "/calculate") {
+		name, ok = LegacyModel, true
+	}
+	if !ok {
+		return ""
+	}
+	if _, ok := s.reg.Get(name); !ok {
+		return ""
+	}
+	return name
+}
+
+func (s *server) writeMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.metrics.write(w, s.reg)
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"net/http"
+	"reflect"
+)
+
+// Browsable page of the OpenAPI document, rendered in the browser
+// from /openapi.json without any external resources.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// The Go types of the bodies, by their name in the document.
+var apiTypes = map[string]reflect.Type{
+	"Model":             reflect.TypeOf(fuzzy.FuzzyController{}),
+	"Inputs":            reflect.TypeOf(Inputs{}),
+	"CalculateResponse": reflect.TypeOf(CalculateResponse{}),
+	"ModelSummary":      reflect.TypeOf(ModelSummary{}),
+	"ModelList":         reflect.TypeOf(ModelList{}),
+	"ReloadStatus":      reflect.TypeOf(ReloadStatus{}),
+	"ErrorResponse":     reflect.TypeOf(ErrorResponse{}),
+	"EvaluationEvent":   reflect.TypeOf(EvaluationEvent{}),
+	"BatchRow":          reflect.TypeOf(BatchRow{}),
+	"ExplainResponse":   reflect.TypeOf(ExplainResponse{}),
+	"SurfaceRequest":    reflect.TypeOf(SurfaceRequest{}),
+	"SurfaceResponse":   reflect.TypeOf(SurfaceResponse{}),
+}
+
+// Generating the OpenAPI 3.1 document of the routes of NewRouter.
+// The schemas of the bodies are derived from their Go types, see
+// fuzzy.SchemaOf, so the document follows changes of the types.
+//
+//	@Return: 1. - the indented document
+//			 2. - error occurred during the encoding
+func OpenAPI() ([]byte, error) {
+	schemas := map[string]interface{}{}
+	for name, t := range apiTypes {
+		schemas[name] = fuzzy.SchemaOf(t)
+	}
+	// What the Go types do not tell: the bounds checked by
+	// checkInputs and surfaceGrid and the codes of the errors.
+	for _, body := range []string{"Inputs", "SurfaceRequest"} {
+		resolution := schemas[body].(map[string]interface{})["properties"].(map[string]interface{})["resolution"].(map[string]interface{})
+		resolution["description"] = "Resolution of every output, mamdani only."
+		resolution["items"] = map[string]interface{}{"type": "integer", "minimum": 2, "maximum": maxResolution}
+	}
+	surface := schemas["SurfaceRequest"].(map[string]interface{})["properties"].(map[string]interface{})
+	surface["axes"].(map[string]interface{})["minItems"] = 1
+	surface["axes"].(map[string]interface{})["maxItems"] = 2
+	points := surface["axes"].(map[string]interface{})["items"].(map[string]interface{})["properties"].(map[string]interface{})["points"].(map[string]interface{})
+	points["minimum"], points["maximum"] = 2, maxSurfaceAxis
+	apiError := schemas["ErrorResponse"].(map[string]interface{})["properties"].(map[string]interface{})["error"].(map[string]interface{})
+	apiError["properties"].(map[string]interface{})["code"].(map[string]interface{})["enum"] = []string{
+		CodeInvalidBody, CodeBodyTooLarge, CodeUnsupportedType, CodeInvalidName, CodeInvalidModel, CodeInvalidInputs,
+		CodeModelNotFound, CodeNotFound, CodeMethodNotAllowed, CodeEvaluation, CodeInternal,
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "Fuzzy controller server",
+			"version":     "1",
+			"description": "Serves named fuzzy models. The json API lives under /v1, the plain-text routes are kept for older clients.",
+		},
+		"paths":      apiPaths(),
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+	b, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// The operations by path and method.
+func apiPaths() map[string]interface{} {
+	v1Errors := map[string]interface{}{
+		"default": response("Error, see the code.", jsonContent(ref("ErrorResponse"))),
+	}
+	with := func(responses map[string]interface{}) map[string]interface{} {
+		for status, resp := range v1Errors {
+			responses[status] = resp
+		}
+		return responses
+	}
+	modelBody := map[string]interface{}{
+		"required":    true,
+		"description": "The model as json, or in any other format ParseFuzzyController reads: yaml, toml or fll.",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": ref("Model")},
+			"text/plain":       map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+		},
+	}
+	inputsBody := map[string]interface{}{
+		"required": true,
+		"content":  jsonContent(ref("Inputs")),
+	}
+	text := map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	textError := response("Error, as text.", text)
+	legacyCalculate := operation("Evaluate the model "+LegacyModel+", as text",
+		"The outputs as a Go slice, e.g. `[2.5]`.", inputsBody, map[string]interface{}{
+			"200":     response("The outputs.", text),
+			"default": textError,
+		})
+
+	return map[string]interface{}{
+		"/v1/models": map[string]interface{}{
+			"get": operation("List the models", "", nil, with(map[string]interface{}{
+				"200": response("The models, sorted by name.", jsonContent(ref("ModelList"))),
+			})),
+		},
+		"/v1/models/{name}": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"put": operation("Add or replace a model", "The model is validated first, an invalid model keeps the old one.", modelBody, with(map[string]interface{}{
+				"200": response("Replaced.", jsonContent(ref("ModelSummary"))),
+				"201": response("Added.", jsonContent(ref("ModelSummary"))),
+			})),
+			"get": operation("Get a model", "", nil, with(map[string]interface{}{
+				"200": response("The model.", jsonContent(ref("Model"))),
+			})),
+			"delete": operation("Remove a model", "", nil, with(map[string]interface{}{
+				"204": response("Removed.", nil),
+			})),
+		},
+		"/v1/models/{name}/calculate": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"post": operation("Evaluate a model", "", inputsBody, with(map[string]interface{}{
+				"200": response("The outputs by name, null where undefined.", jsonContent(ref("CalculateResponse"))),
+			})),
+		},
+		"/v1/models/{name}/batch": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"post": map[string]interface{}{
+				"summary": "Evaluate many input vectors",
+				"description": "The rows are a json array of input vectors or csv with a header row naming the inputs, other columns are ignored. " +
+					"The results are streamed back in the same format and order: a json array of BatchRow, or csv with the columns of the inputs, the outputs and `error`. " +
+					"A row which can not be evaluated gets its own error.",
+				"parameters": []interface{}{map[string]interface{}{
+					"name":        "resolution",
+					"in":          "query",
+					"description": "Resolution of every output, comma separated, mamdani only.",
+					"schema":      map[string]interface{}{"type": "string", "pattern": "^[0-9]+(,[0-9]+)*$"},
+				}},
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{
+							"type":  "array",
+							"items": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}},
+						}},
+						"text/csv": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+				"responses": with(map[string]interface{}{
+					"200": response("The results.", map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "array", "items": ref("BatchRow")}},
+						"text/csv":         map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					}),
+				}),
+			},
+		},
+		"/v1/models/{name}/events": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"get": operation("Stream the evaluations of a model",
+				"Server-Sent Events: every evaluation of the model is a message with an EvaluationEvent as data. "+
+					"A slow client misses evaluations instead of delaying them, a `dropped` event with data `{\"dropped\": n}` tells how many.",
+				nil, with(map[string]interface{}{
+					"200": response("The event stream.", map[string]interface{}{
+						"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{
+							"type":        "string",
+							"description": "Messages with the json of #/components/schemas/EvaluationEvent as data.",
+						}},
+					}),
+				})),
+		},
+		"/v1/models/{name}/explain": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"post": operation("Explain an evaluation of a model",
+				"The evaluation of the inputs step by step: the membership of every label of the inputs, "+
+					"the strength of every rule, the activation of the output labels and, for mamdani, the aggregated curve of every output.",
+				inputsBody, with(map[string]interface{}{
+					"200": response("The trace.", jsonContent(ref("ExplainResponse"))),
+				})),
+		},
+		"/v1/models/{name}/surface": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"post": operation("Evaluate a model over a grid",
+				fmt.Sprintf("The outputs over a grid of one or two inputs, the other inputs fixed, at most %v points. "+
+					"For mamdani, the points times the sum of the resolutions are at most %v.", maxSurfacePoints, maxSurfaceCurves),
+				map[string]interface{}{"required": true, "content": jsonContent(ref("SurfaceRequest"))},
+				with(map[string]interface{}{
+					"200": response("The outputs on the grid.", jsonContent(ref("SurfaceResponse"))),
+				})),
+		},
+		"/v1/reload": map[string]interface{}{
+			"get": operation("Status of the reloads of the model files", "", nil, with(map[string]interface{}{
+				"200": response("The reload status.", jsonContent(ref("ReloadStatus"))),
+			})),
+		},
+		"/models": map[string]interface{}{
+			"get": operation("List the names of the models", "", nil, map[string]interface{}{
+				"200": response("The names, sorted.", jsonContent(map[string]interface{}{
+					"type": "array", "items": map[string]interface{}{"type": "string"},
+				})),
+			}),
+		},
+		"/models/{name}": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"put": operation("Add or replace a model", "", modelBody, map[string]interface{}{
+				"201":     response("Added.", nil),
+				"204":     response("Replaced.", nil),
+				"default": textError,
+			}),
+			"get": operation("Get a model", "", nil, map[string]interface{}{
+				"200":     response("The model.", jsonContent(ref("Model"))),
+				"default": textError,
+			}),
+			"delete": operation("Remove a model", "", nil, map[string]interface{}{
+				"204":     response("Removed.", nil),
+				"default": textError,
+			}),
+		},
+		"/models/{name}/calculate": map[string]interface{}{
+			"parameters": []interface{}{nameParam()},
+			"post": operation("Evaluate a model, as text", "The outputs as a Go slice, e.g. `[2.5]`.", inputsBody, map[string]interface{}{
+				"200":     response("The outputs.", text),
+				"default": textError,
+			}),
+		},
+		"/fuzzCon": map[string]interface{}{
+			"post": operation("Replace the model "+LegacyModel, "", map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(ref("Model")),
+			}, map[string]interface{}{
+				"200":     response("Replaced.", nil),
+				"default": textError,
+			}),
+		},
+		// The route answers any method, GET and POST are the ones
+		// clients use.
+		"/calculate": map[string]interface{}{
+			"get":  legacyCalculate,
+			"post": legacyCalculate,
+		},
+		"/metrics": map[string]interface{}{
+			"get": operation("Metrics in the Prometheus text format", "", nil, map[string]interface{}{
+				"200": response("The metrics.", text),
+			}),
+		},
+		"/openapi.json": map[string]interface{}{
+			"get": operation("This document", "", nil, map[string]interface{}{
+				"200": response("The document.", jsonContent(map[string]interface{}{"type": "object"})),
+			}),
+		},
+		"/docs": map[string]interface{}{
+			"get": operation("Browsable page of this document", "", nil, map[string]interface{}{
+				"200": response("The page.", map[string]interface{}{
+					"text/html": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+				}),
+			}),
+		},
+	}
+}
+
+func operation(summary, description string, body, responses map[string]interface{}) map[string]interface{} {
+	op := map[string]interface{}{"summary": summary, "responses": responses}
+	if description != "" {
+		op["description"] = description
+	}
+	if body != nil {
+		op["requestBody"] = body
+	}
+	return op
+}
+
+func response(description string, content map[string]interface{}) map[string]interface{} {
+	resp := map[string]interface{}{"description": description}
+	if content != nil {
+		resp["content"] = content
+	}
+	return resp
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func nameParam() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        "name",
+		"in":          "path",
+		"required":    true,
+		"description": "Name of the model.",
+		"schema":      map[string]interface{}{"type": "string", "pattern": modelName.String()},
+	}
+}
+
+func (s *server) openAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := OpenAPI()
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
+
+func (s *server) docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
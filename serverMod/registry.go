@@ -0,0 +1,186 @@
+package server
+
+import (
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Names of the models: letters, digits, '_', '-' and '.', not
+// starting with '.', so that a name is also a valid file name.
+var modelName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]{0,63}$`)
+
+// Named models shared by the clients of the server. Every model
+// is held by a fuzzy.Handle, so evaluations run concurrently and
+// a replaced model lets the evaluations in flight finish.
+type Registry struct {
+	mu      sync.RWMutex
+	handles map[string]*fuzzy.Handle
+	store   *Store
+	reloads reloads
+	// Ordering the changes of a model, mu is only held to look up
+	// or change handles.
+	names nameLocks
+}
+
+// Locks by model name: the puts, deletes and reloads of a model run
+// one after the other, while those of other models and all the
+// evaluations go on.
+type nameLocks struct {
+	mu    sync.Mutex
+	locks map[string]*nameLock
+}
+
+type nameLock struct {
+	sync.Mutex
+	// Holders and waiters, the lock is dropped without any.
+	refs int
+}
+
+// Locking name, the returned function unlocks it.
+func (nl *nameLocks) lock(name string) func() {
+	nl.mu.Lock()
+	if nl.locks == nil {
+		nl.locks = make(map[string]*nameLock)
+	}
+	l, ok := nl.locks[name]
+	if !ok {
+		l = &nameLock{}
+		nl.locks[name] = l
+	}
+	l.refs++
+	nl.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		nl.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(nl.locks, name)
+		}
+		nl.mu.Unlock()
+	}
+}
+
+// Whether name is a valid model name.
+func ValidModelName(name string) bool {
+	return modelName.MatchString(name)
+}
+
+// Registry creator, without models.
+func NewRegistry() *Registry {
+	return &Registry{handles: make(map[string]*fuzzy.Handle)}
+}
+
+// Persisting the models of the registry in st from now on: Put
+// and Delete change the store before the registry, and leave
+// both unchanged if the store fails. The models already in the
+// registry are not written, see Store.LoadInto.
+func (reg *Registry) SetStore(st *Store) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.store = st
+}
+
+// Adding or replacing a model.
+//
+//	@Params: name - the name of the model.
+//
+//			 fc - the fuzzyController, which is copied and
+//			 validated first.
+//
+//	@Return: 1. - true if the model is new, false if it
+//			 replaced a model of the same name
+//			 2. - error by the name, by validating the model
+//			 or *StoreError, the registry is left unchanged
+func (reg *Registry) Put(name string, fc fuzzy.FuzzyController) (bool, error) {
+	return reg.put(name, fc, true)
+}
+
+func (reg *Registry) put(name string, fc fuzzy.FuzzyController, persist bool) (bool, error) {
+	if !ValidModelName(name) {
+		return false, fmt.Errorf("error by model name %q, expect %v", name, modelName)
+	}
+	defer reg.names.lock(name)()
+	// Validating and writing the model take their time, the other
+	// models are served meanwhile.
+	h, err := fuzzy.NewHandle(fc)
+	if err != nil {
+		return false, err
+	}
+	reg.mu.RLock()
+	st := reg.store
+	reg.mu.RUnlock()
+	if persist && st != nil {
+		if err := st.Save(name, fc); err != nil {
+			return false, err
+		}
+	}
+	return reg.install(name, h, fc)
+}
+
+// Serving the model of h under name, with the lock of the name
+// held: a new model is added, a known one swapped.
+//
+//	@Return: 1. - true if the model is new
+//			 2. - error by validating the model
+func (reg *Registry) install(name string, h *fuzzy.Handle, fc fuzzy.FuzzyController) (bool, error) {
+	reg.mu.Lock()
+	old, ok := reg.handles[name]
+	if !ok {
+		reg.handles[name] = h
+	}
+	reg.mu.Unlock()
+	if ok {
+		// Swapping keeps the handle of the model, which the
+		// evaluations in flight hold.
+		return false, old.Swap(fc)
+	}
+	return true, nil
+}
+
+// The handle of a model, false if there is no such model.
+func (reg *Registry) Get(name string) (*fuzzy.Handle, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	h, ok := reg.handles[name]
+	return h, ok
+}
+
+// Removing a model.
+//
+//	@Return: 1. - false if there is no such model
+//			 2. - *StoreError, the model is kept
+func (reg *Registry) Delete(name string) (bool, error) {
+	defer reg.names.lock(name)()
+	reg.mu.RLock()
+	_, ok := reg.handles[name]
+	st := reg.store
+	reg.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	if st != nil {
+		if err := st.Delete(name); err != nil {
+			return true, err
+		}
+	}
+	reg.mu.Lock()
+	delete(reg.handles, name)
+	reg.mu.Unlock()
+	return true, nil
+}
+
+// The names of the models in ascending order.
+func (reg *Registry) Names() []string {
+	reg.mu.RLock()
+	names := make([]string, 0, len(reg.handles))
+	for name := range reg.handles {
+		names = append(names, name)
+	}
+	reg.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	fuzzy "fuzzy/fuzzyMod"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Actions of the reloads of model files.
+const (
+	ReloadLoaded  = "loaded"  // the model of the file is served
+	ReloadRemoved = "removed" // the file is gone, so is the model
+	ReloadFailed  = "failed"  // the file is invalid, the last valid model is kept
+)
+
+// Status of the reloads of the model files of a registry.
+type ReloadStatus struct {
+	// Whether the registry has a store to reload from.
+	Enabled bool `json:"enabled"`
+	// Time of the last check of the store, null before the first.
+	LastCheck *time.Time `json:"last_check"`
+	// Error of the last check, listing the store failed.
+	Error    string `json:"error,omitempty"`
+	Reloads  int    `json:"reloads"`
+	Failures int    `json:"failures"`
+	// The last reload of every file reloaded so far, by model name.
+	Files []FileReload `json:"files"`
+}
+
+type FileReload struct {
+	Model  string    `json:"model"`
+	File   string    `json:"file"`
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Reload status of a registry.
+type reloads struct {
+	mu     sync.Mutex
+	status ReloadStatus
+	files  map[string]FileReload
+}
+
+func (rl *reloads) record(file FileReload) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.files == nil {
+		rl.files = make(map[string]FileReload)
+	}
+	rl.files[file.Model] = file
+	if file.Action == ReloadFailed {
+		rl.status.Failures++
+	} else {
+		rl.status.Reloads++
+	}
+}
+
+// Checking the store of the registry once for files changed by
+// others than the registry: changed files are validated and their
+// models swapped in, an invalid file keeps the last valid model
+// and is not tried again before it changes, and the model of a
+// removed file is removed. Nothing is done without a store.
+//
+//	@Return: the reload status after the check
+func (reg *Registry) Reload() ReloadStatus {
+	reg.mu.RLock()
+	st := reg.store
+	reg.mu.RUnlock()
+	if st == nil {
+		return reg.ReloadStatus()
+	}
+
+	changed, removed, err := st.changes()
+	now := time.Now()
+	reg.reloads.mu.Lock()
+	reg.reloads.status.LastCheck = &now
+	reg.reloads.status.Error = ""
+	if err != nil {
+		reg.reloads.status.Error = err.Error()
+	}
+	reg.reloads.mu.Unlock()
+	if err != nil {
+		log.WithField("dir", st.Dir()).Errorf("error by checking the models: %v", err)
+		return reg.ReloadStatus()
+	}
+
+	for _, name := range changed {
+		reg.reloads.record(reg.reloadFile(st, name))
+	}
+	for _, name := range removed {
+		if file, ok := reg.removeFile(st, name); ok {
+			reg.reloads.record(file)
+		}
+	}
+	return reg.ReloadStatus()
+}
+
+// Loading the changed file of a model, under the lock of its
+// name so that no upload of it interleaves. The file is read and
+// the model compiled before the registry is locked for the swap.
+func (reg *Registry) reloadFile(st *Store, name string) FileReload {
+	defer reg.names.lock(name)()
+	file := FileReload{Model: name, File: st.Path(name), Time: time.Now(), Action: ReloadLoaded}
+	logger := log.WithField("model", name).WithField("file", file.File)
+
+	fc, err := st.Load(name)
+	var h *fuzzy.Handle
+	if err == nil {
+		h, err = fuzzy.NewHandle(fc)
+	}
+	if err == nil {
+		_, err = reg.install(name, h, fc)
+	}
+	if err != nil {
+		file.Action = ReloadFailed
+		file.Error = err.Error()
+		if _, ok := reg.Get(name); ok {
+			logger.Errorf("reload failed, the last valid model is kept: %v", err)
+		} else {
+			logger.Errorf("reload failed, the model is not served: %v", err)
+		}
+		return file
+	}
+	logger.Info("model reloaded")
+	return file
+}
+
+// Removing the model of a removed file, false if the file is
+// back meanwhile, which the next check reloads.
+func (reg *Registry) removeFile(st *Store, name string) (FileReload, bool) {
+	defer reg.names.lock(name)()
+	file := FileReload{Model: name, File: st.Path(name), Time: time.Now(), Action: ReloadRemoved}
+	if _, err := os.Stat(file.File); err == nil {
+		return file, false
+	}
+	st.stamp(name)
+	reg.mu.Lock()
+	delete(reg.handles, name)
+	reg.mu.Unlock()
+	log.WithField("model", name).WithField("file", file.File).Info("model file removed, model removed")
+	return file, true
+}
+
+// The reload status, see Reload.
+func (reg *Registry) ReloadStatus() ReloadStatus {
+	reg.mu.RLock()
+	enabled := reg.store != nil
+	reg.mu.RUnlock()
+
+	reg.reloads.mu.Lock()
+	defer reg.reloads.mu.Unlock()
+	status := reg.reloads.status
+	status.Enabled = enabled
+	status.Files = make([]FileReload, 0, len(reg.reloads.files))
+	for _, file := range reg.reloads.files {
+		status.Files = append(status.Files, file)
+	}
+	sort.Slice(status.Files, func(i, j int) bool { return status.Files[i].Model < status.Files[j].Model })
+	return status
+}
+
+// Checking the store every interval, see Reload, until ctx is
+// done.
+func (reg *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reg.Reload()
+		}
+	}
+}
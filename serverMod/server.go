@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// Largest request body accepted, models included.
+const maxBody = 8 << 20
+
+// The model of the legacy routes /fuzzCon and /calculate, which is
+// also served as /models/default.
+const LegacyModel = "default"
+
+// Request body of the calculations. Without resolutions the
+// mamdani outputs use fuzzy.DefaultResolution.
+type Inputs struct {
+	InputX     []float64 `json:"input_x"`
+	Resolution []int     `json:"resolution,omitempty"`
+}
+
+// Routes of the named models of reg:
+//
+//	GET    /models                  - names of the models, json array
+//	PUT    /models/{name}           - add or replace a model, in any format
+//	GET    /models/{name}           - the model, json
+//	DELETE /models/{name}           - remove a model
+//	POST   /models/{name}/calculate - evaluate Inputs
+//
+// the legacy routes of the model LegacyModel:
+//
+//	POST   /fuzzCon                 - replace the model, json
+//	*      /calculate               - evaluate Inputs
+//
+// the json API of the same under /v1, see routesV1, and
+//
+//	GET    /metrics                 - metrics in the Prometheus text format
+//	GET    /openapi.json            - the OpenAPI document of the routes
+//	GET    /docs                    - browsable page of the document
+func NewRouter(reg *Registry) *mux.Router {
+	r := mux.NewRouter()
+	s := &server{reg: reg, metrics: newMetrics()}
+	r.Use(s.measure)
+	r.NotFoundHandler = s.measure(http.HandlerFunc(notFound))
+	r.MethodNotAllowedHandler = s.measure(http.HandlerFunc(methodNotAllowed))
+	r.HandleFunc("/metrics", s.writeMetrics).Methods("GET")
+	r.HandleFunc("/openapi.json", s.openAPI).Methods("GET")
+	r.HandleFunc("/docs", s.docs).Methods("GET")
+	s.routesV1(r)
+	r.HandleFunc("/models", s.listModels).Methods("GET")
+	r.HandleFunc("/models/{name}", s.putModel).Methods("PUT")
+	r.HandleFunc("/models/{name}", s.getModel).Methods("GET")
+	r.HandleFunc("/models/{name}", s.deleteModel).Methods("DELETE")
+	r.HandleFunc("/models/{name}/calculate", s.calculate).Methods("POST")
+	r.HandleFunc("/fuzzCon", s.newController).Methods("POST")
+	r.HandleFunc("/calculate", s.calculate)
+	return r
+}
+
+type server struct {
+	reg     *Registry
+	metrics *metrics
+	events  events
+}
+
+func (s *server) listModels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.reg.Names())
+}
+
+func (s *server) putModel(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fc, err := fuzzy.ParseFuzzyController(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := s.reg.Put(modelOf(r), fc)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *server) getModel(w http.ResponseWriter, r *http.Request) {
+	h, ok := s.handle(w, r)
+	if !ok {
+		return
+	}
+	fc := h.Model()
+	str, err := fc.ToJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(str))
+}
+
+func (s *server) deleteModel(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	found, err := s.reg.Delete(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("no model %q", name), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Replacing the legacy model by a json model.
+func (s *server) newController(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fc, err := fuzzy.NewFuzzyController(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.reg.Put(LegacyModel, fc); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+	}
+}
+
+// The outputs are written as text, as the legacy /calculate
+// always did.
+func (s *server) calculate(w http.ResponseWriter, r *http.Request) {
+	h, ok := s.handle(w, r)
+	if !ok {
+		return
+	}
+	name := modelOf(r)
+	var inputs Inputs
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&inputs); err != nil {
+		s.metrics.evalError(name, CodeInvalidBody)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	snap := h.Snapshot()
+	if msg := checkInputs(snap.Info(), inputs); msg != "" {
+		s.metrics.evalError(name, CodeInvalidInputs)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	info := snap.Info()
+	rst := make([]float64, len(info.Outputs))
+	var strengths []float64
+	if s.events.watched(name) {
+		strengths = make([]float64, info.Rules)
+	}
+	fired, err := snap.EvaluateRules(rst, strengths, inputs.InputX, inputs.Resolution...)
+	if err != nil {
+		// The inputs are checked, a failure is the server's as
+		// for /v1.
+		s.metrics.evalError(name, CodeEvaluation)
+		log.WithField("model", name).Errorf("evaluation failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if fired == 0 {
+		s.metrics.noRuleFired(name)
+	}
+	if strengths != nil {
+		s.publish(name, info, inputs.InputX, rst, strengths, fired)
+	}
+	w.Write([]byte(fmt.Sprintf("%v\n", rst)))
+}
+
+// Status of an error of the registry: the store failing is an
+// error of the server, anything else an error of the client.
+func errorStatus(err error) int {
+	var storeErr *StoreError
+	if errors.As(err, &storeErr) {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// The model named by the route, LegacyModel for the legacy
+// routes.
+func modelOf(r *http.Request) string {
+	if name, ok := mux.Vars(r)["name"]; ok {
+		return name
+	}
+	return LegacyModel
+}
+
+// The handle of the model named by the route, answering 404 if
+// there is no such model.
+func (s *server) handle(w http.ResponseWriter, r *http.Request) (*fuzzy.Handle, bool) {
+	name := modelOf(r)
+	h, ok := s.reg.Get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no model %q", name), http.StatusNotFound)
+	}
+	return h, ok
+}
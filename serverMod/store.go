@@ -0,0 +1,178 @@
+package server
+
+import (
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directory of the models of a registry, one json file per model
+// named after it, <name>.json. The files are replaced atomically,
+// a crash never leaves a partial model behind. The store keeps the
+// modification time and size of the files it wrote or read, to
+// tell the files changed by others.
+type Store struct {
+	dir    string
+	mu     sync.Mutex
+	stamps map[string]stamp
+}
+
+// Modification time and size of a file.
+type stamp struct {
+	mod  time.Time
+	size int64
+}
+
+// Error of the store, as opposed to an invalid model.
+type StoreError struct {
+	Op   string
+	Name string
+	Err  error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("error by %v of model %q: %v", e.Op, e.Name, e.Err)
+}
+
+// Store creator, creating the directory if needed.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, stamps: make(map[string]stamp)}, nil
+}
+
+// The directory of the store.
+func (st *Store) Dir() string {
+	return st.dir
+}
+
+// The file of a model.
+func (st *Store) Path(name string) string {
+	return filepath.Join(st.dir, name+".json")
+}
+
+// Writing a model, replacing the file atomically.
+func (st *Store) Save(name string, fc fuzzy.FuzzyController) error {
+	if err := fc.Save(st.Path(name)); err != nil {
+		return &StoreError{"saving", name, err}
+	}
+	st.stamp(name)
+	return nil
+}
+
+// Removing the file of a model, if any.
+func (st *Store) Delete(name string) error {
+	if err := os.Remove(st.Path(name)); err != nil && !os.IsNotExist(err) {
+		return &StoreError{"deleting", name, err}
+	}
+	st.mu.Lock()
+	delete(st.stamps, name)
+	st.mu.Unlock()
+	return nil
+}
+
+// The names of the models in the directory, in ascending order.
+// Hidden files, such as the temporary files of the writes, and
+// files not named after a valid model name are left out.
+func (st *Store) Names() ([]string, error) {
+	entries, err := ioutil.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || name == entry.Name() || !ValidModelName(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Reading a model of the store.
+func (st *Store) Load(name string) (fuzzy.FuzzyController, error) {
+	// Stamped before reading: a change while reading is seen as
+	// a change by the next check.
+	st.stamp(name)
+	return fuzzy.LoadFuzzyController(st.Path(name))
+}
+
+// Recording the current stamp of the file of a model.
+func (st *Store) stamp(name string) {
+	info, err := os.Stat(st.Path(name))
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err != nil {
+		delete(st.stamps, name)
+		return
+	}
+	st.stamps[name] = stamp{info.ModTime(), info.Size()}
+}
+
+// The models whose files changed since the store wrote or read
+// them last, including new files, and the models whose files were
+// removed, both in ascending order. Changes keeping the size
+// within the resolution of the modification times are missed.
+func (st *Store) changes() (changed []string, removed []string, err error) {
+	names, err := st.Names()
+	if err != nil {
+		return nil, nil, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+		info, err := os.Stat(st.Path(name))
+		if err != nil {
+			// Removed meanwhile, seen by the next check.
+			continue
+		}
+		if old, ok := st.stamps[name]; !ok || !old.mod.Equal(info.ModTime()) || old.size != info.Size() {
+			changed = append(changed, name)
+		}
+	}
+	for name := range st.stamps {
+		if !present[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed, nil
+}
+
+// Loading all the models of the store into reg, without writing
+// them back. The invalid files are skipped and reported, the
+// others loaded all the same.
+//
+//	@Return: 1. - the names of the loaded models
+//			 2. - the errors of the skipped files
+func (st *Store) LoadInto(reg *Registry) ([]string, []error) {
+	names, err := st.Names()
+	if err != nil {
+		return nil, []error{err}
+	}
+	var loaded []string
+	var errs []error
+	for _, name := range names {
+		fc, err := st.Load(name)
+		if err == nil {
+			_, err = reg.put(name, fc, false)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		loaded = append(loaded, name)
+	}
+	return loaded, errs
+}
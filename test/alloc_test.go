@@ -0,0 +1,49 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"testing"
+)
+
+// Evaluations allocate nothing once the grids are built.
+func TestEvaluateAllocs(t *testing.T) {
+	for _, c := range []struct {
+		file   string
+		defuzz string
+	}{
+		{"./mamdaniModel.json", "centroid"},
+		{"./mamdaniModel.json", "bisector"},
+		{"./mamdaniModel.json", "mom"},
+		{"./sugenoModel.json", "wtaver"},
+	} {
+		fc, err := fuzzy.LoadFuzzyController(c.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc.System.Defuzzmethod = c.defuzz
+		in := []float64{3, -4}
+		if _, err := fc.Evaluate(in); err != nil {
+			t.Fatal(err)
+		}
+		if n := testing.AllocsPerRun(100, func() { fc.Evaluate(in) }); n != 0 {
+			t.Errorf("%v/%v: Evaluate allocates %v times", c.file, c.defuzz, n)
+		}
+		if n := testing.AllocsPerRun(100, func() { fc.Evaluate(in, 500) }); n != 0 {
+			t.Errorf("%v/%v: Evaluate with resolution allocates %v times", c.file, c.defuzz, n)
+		}
+	}
+}
+
+func BenchmarkEvaluateMamdani(b *testing.B) {
+	fc, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	in := []float64{3, -4}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := fc.Evaluate(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"encoding/json"
+	fuzzy "fuzzy/fuzzyMod"
+	server "fuzzy/serverMod"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIv1(t *testing.T) {
+	srv := httptest.NewServer(server.NewRouter(server.NewRegistry()))
+	defer srv.Close()
+
+	// No rule fires for x beyond 2, the output is undefined.
+	gap, err := fuzzy.NewBuilder().Name("gap").Method("sugeno").
+		Input("x", 0, 10).Term("A", fuzzy.Tri(0, 1, 2)).
+		Output("u", 0, 10).Term("C", fuzzy.Const(4)).
+		Rule([]string{"A"}, "C").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gapJSON, err := gap.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"PUT", "/v1/models/m", readModel(t, "./mamdaniModel.json"), 201, ""},
+		{"PUT", "/v1/models/m", readModel(t, "./mamdaniModel.yaml"), 200, ""},
+		{"PUT", "/v1/models/gap", gapJSON, 201, ""},
+		{"PUT", "/v1/models/.m", gapJSON, 400, server.CodeInvalidName},
+		{"PUT", "/v1/models/x", `{"system": {"method": "avg"}}`, 400, server.CodeInvalidModel},
+		{"PUT", "/v1/models/x", strings.Repeat(" ", 8<<20+1), 413, server.CodeBodyTooLarge},
+		{"GET", "/v1/models/x", "", 404, server.CodeModelNotFound},
+		{"POST", "/v1/models/x/calculate", `{"input_x": [0, 0]}`, 404, server.CodeModelNotFound},
+		{"POST", "/v1/models/m/calculate", `{"input_x": [0, 0`, 400, server.CodeInvalidBody},
+		{"POST", "/v1/models/m/calculate", `{"inputs": [0, 0]}`, 400, server.CodeInvalidBody},
+		{"POST", "/v1/models/m/calculate", `{"input_x": [0]}`, 400, server.CodeInvalidInputs},
+		{"POST", "/v1/models/m/calculate", `{"input_x": [0, 0], "resolution": [1]}`, 400, server.CodeInvalidInputs},
+		{"POST", "/v1/models/m/calculate", `{"input_x": [0, 0], "resolution": [100, 100]}`, 400, server.CodeInvalidInputs},
+		{"GET", "/v1/models/m/calculate", "", 405, server.CodeMethodNotAllowed},
+		{"GET", "/v1/nothing", "", 404, server.CodeNotFound},
+		// The legacy routes answer errors as well.
+		{"POST", "/fuzzCon", `{"system": `, 400, ""},
+		{"POST", "/calculate", `{"input_x": [0, 0]}`, 404, ""},
+		{"POST", "/models/m/calculate", `{"input_x": [0, 0], "resolution": [0]}`, 400, ""},
+	} {
+		status, body := request(t, srv, c.method, c.path, c.body)
+		if status != c.status {
+			t.Errorf("%v %v: %v %v, expect %v", c.method, c.path, status, body, c.status)
+			continue
+		}
+		if c.code == "" {
+			continue
+		}
+		var resp server.ErrorResponse
+		if err := json.Unmarshal([]byte(body), &resp); err != nil || resp.Error.Code != c.code || resp.Error.Message == "" {
+			t.Errorf("%v %v: %q, expect code %v", c.method, c.path, body, c.code)
+		}
+	}
+
+	status, body := request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [10, -5], "resolution": [300]}`)
+	var calc server.CalculateResponse
+	if err := json.Unmarshal([]byte(body), &calc); err != nil || status != 200 {
+		t.Fatalf("calculate: %v %v %v", status, body, err)
+	}
+	fc, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := fc.Evaluate([]float64{10, -5}, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := fc.Outputs[0].Name
+	if calc.Model != "m" || calc.Method != "mamdani" || calc.Outputs[out] == nil || *calc.Outputs[out] != want[0] ||
+		len(calc.Resolution) != 1 || calc.Inputs[fc.Inputs[1].Name] != -5 {
+		t.Errorf("calculate: %+v, expect %v = %v", calc, out, want[0])
+	}
+
+	status, body = request(t, srv, "POST", "/v1/models/gap/calculate", `{"input_x": [5]}`)
+	if status != 200 || !strings.Contains(body, `"outputs":{"u":null}`) {
+		t.Errorf("calculate without firing rules: %v %v", status, body)
+	}
+
+	status, body = request(t, srv, "GET", "/v1/models", "")
+	var list server.ModelList
+	if err := json.Unmarshal([]byte(body), &list); err != nil || status != 200 || len(list.Models) != 2 ||
+		list.Models[0].Name != "gap" || list.Models[1].Method != "mamdani" || len(list.Models[1].Inputs) != 2 {
+		t.Errorf("list: %v %v", status, body)
+	}
+	if status, _ := request(t, srv, "DELETE", "/v1/models/gap", ""); status != 204 {
+		t.Errorf("delete: %v", status)
+	}
+
+	// A valid model which fails to evaluate: the output range is too
+	// wide for its grid. Both calculate routes answer a 500.
+	wide, err := fuzzy.NewBuilder().Name("wide").Method("mamdani").Defuzzify("bisector").
+		Input("x", 0, 10).Term("A", fuzzy.Tri(0, 5, 10)).
+		Output("u", -1e308, 1e308).Term("C", fuzzy.Tri(-1, 0, 1)).
+		Rule([]string{"A"}, "C").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wideJSON, err := wide.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, srv, "PUT", "/v1/models/wide", wideJSON)
+	status, body = request(t, srv, "POST", "/v1/models/wide/calculate", `{"input_x": [5]}`)
+	var resp server.ErrorResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil || status != 500 || resp.Error.Code != server.CodeEvaluation {
+		t.Errorf("v1 calculate failing: %v %v", status, body)
+	}
+	if status, body = request(t, srv, "POST", "/models/wide/calculate", `{"input_x": [5]}`); status != 500 {
+		t.Errorf("legacy calculate failing: %v %v", status, body)
+	}
+}
@@ -0,0 +1,144 @@
+package test
+
+import (
+	"context"
+	"errors"
+	fuzzy "fuzzy/fuzzyMod"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestEvaluateBatch(t *testing.T) {
+	for _, file := range []string{"./mamdaniModel.json", "./sugenoModel.json"} {
+		fc, err := fuzzy.LoadFuzzyController(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rnd := rand.New(rand.NewSource(1))
+		inputs := make([][]float64, 500)
+		for i := range inputs {
+			inputs[i] = []float64{-30 + 60*rnd.Float64(), -30 + 60*rnd.Float64()}
+		}
+		// Rows of the wrong size fail alone.
+		inputs[7] = []float64{1}
+		inputs[300] = nil
+
+		outputs, err := fc.EvaluateBatch(inputs, 200)
+		var batchErr *fuzzy.BatchError
+		if !errors.As(err, &batchErr) || len(batchErr.Rows) != 2 ||
+			batchErr.Rows[0].Row != 7 || batchErr.Rows[1].Row != 300 {
+			t.Fatalf("%v: expect errors of the rows 7 and 300, got %v", file, err)
+		}
+		if len(outputs) != len(inputs) || outputs[7] != nil || outputs[300] != nil {
+			t.Fatalf("%v: unexpected outputs of the failed rows", file)
+		}
+
+		// The stream gives the same results in the same order.
+		in := make(chan []float64)
+		go func() {
+			for _, row := range inputs {
+				in <- row
+			}
+			close(in)
+		}()
+		results, err := fc.EvaluateStream(context.Background(), in, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for res := range results {
+			if res.Index != n {
+				t.Fatalf("%v: result %v at position %v", file, res.Index, n)
+			}
+			if (res.Err != nil) != (outputs[n] == nil) {
+				t.Errorf("%v: row %v: stream error %v", file, n, res.Err)
+			}
+			n++
+		}
+		if n != len(inputs) {
+			t.Errorf("%v: %v results of %v rows", file, n, len(inputs))
+		}
+
+		// Both match the single evaluations.
+		for i, row := range inputs {
+			if outputs[i] == nil {
+				continue
+			}
+			want, err := fc.Evaluate(row, 200)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !closeOrNaN(outputs[i][0], want[0], 0) {
+				t.Errorf("%v: row %v: %v, expect %v", file, i, outputs[i][0], want[0])
+			}
+		}
+	}
+}
+
+// A stream whose reader stops is ended by its context, and its
+// goroutines with it.
+func TestEvaluateStreamCancel(t *testing.T) {
+	fc, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan []float64)
+	go func() {
+		// Endless, as a producer waiting for the reader.
+		for {
+			select {
+			case in <- []float64{0, 0}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	results, err := fc.EvaluateStream(ctx, in, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < 10; k++ {
+		if res := <-results; res.Index != k || res.Err != nil {
+			t.Fatalf("result %v: %+v", k, res)
+		}
+	}
+	// The reader stops reading.
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v goroutines left running, %v before the stream", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The results end, possibly after a last one in flight.
+	for range results {
+	}
+}
+
+func TestClone(t *testing.T) {
+	fc, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone, err := fc.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.Inputs[0].Mf[0].Params[0] = -20
+	want, err := fc.Evaluate([]float64{-8, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := clone.Evaluate([]float64{-8, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] == want[0] {
+		t.Errorf("clone shares the model with the original")
+	}
+}
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	server "fuzzy/serverMod"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func postBatch(t *testing.T, srv *httptest.Server, path, contentType, body string) (int, string, string) {
+	t.Helper()
+	resp, err := http.Post(srv.URL+path, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(b)
+}
+
+func TestBatchAPI(t *testing.T) {
+	srv := httptest.NewServer(server.NewRouter(server.NewRegistry()))
+	defer srv.Close()
+	model := readModel(t, "./mamdaniModel.json")
+	request(t, srv, "PUT", "/v1/models/m", model)
+	fc, err := fuzzy.NewFuzzyController(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := func(e, ec float64) float64 {
+		out, err := fc.Evaluate([]float64{e, ec}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out[0]
+	}
+
+	// json, in the order of the rows, failed rows do not stop the
+	// others.
+	status, contentType, body := postBatch(t, srv, "/v1/models/m/batch?resolution=100", "application/json",
+		`[[2.13, 0.2], [0], ["a", "b"], {"e": 1}, [0, 0]]`)
+	var rows []server.BatchRow
+	if err := json.Unmarshal([]byte(body), &rows); err != nil || status != 200 || contentType != "application/json" || len(rows) != 5 {
+		t.Fatalf("json batch: %v %v %v", status, err, body)
+	}
+	for k, row := range rows {
+		if row.Row != k {
+			t.Errorf("row %v numbered %v", k, row.Row)
+		}
+	}
+	if rows[0].Error != nil || *rows[0].Outputs["u"] != expect(2.13, 0.2) || *rows[4].Outputs["u"] != expect(0, 0) {
+		t.Errorf("json batch results: %v", body)
+	}
+	for _, k := range []int{1, 2, 3} {
+		if rows[k].Error == nil || rows[k].Error.Code != server.CodeInvalidInputs || rows[k].Outputs != nil {
+			t.Errorf("json batch row %v: %+v", k, rows[k])
+		}
+	}
+	schemas := openAPI(t)["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	var v []interface{}
+	json.Unmarshal([]byte(body), &v)
+	for k, row := range v {
+		if err := conforms(schemas, schemas["BatchRow"].(map[string]interface{}), row, fmt.Sprintf("BatchRow[%v]", k)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// csv, with the columns in any order and other columns.
+	status, contentType, body = postBatch(t, srv, "/v1/models/m/batch?resolution=100", "text/csv; charset=utf-8",
+		"ec,id,e\n0.2,a,2.13\nx,b,0\n0,c\n0, d, 0\n")
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil || status != 200 || contentType != "text/csv" || len(records) != 5 {
+		t.Fatalf("csv batch: %v %v %q", status, err, body)
+	}
+	if strings.Join(records[0], ",") != "e,ec,u,error" {
+		t.Errorf("csv header %v", records[0])
+	}
+	if records[1][0] != "2.13" || records[1][1] != "0.2" || records[1][2] != strconv.FormatFloat(expect(2.13, 0.2), 'g', -1, 64) || records[1][3] != "" ||
+		records[4][2] != strconv.FormatFloat(expect(0, 0), 'g', -1, 64) {
+		t.Errorf("csv batch results: %q", body)
+	}
+	for _, k := range []int{2, 3} {
+		if records[k][2] != "" || !strings.HasPrefix(records[k][3], server.CodeInvalidInputs+": ") {
+			t.Errorf("csv batch row %v: %v", k, records[k])
+		}
+	}
+
+	// Errors of the whole body are answered before any result.
+	for _, c := range []struct {
+		path, contentType, body string
+		status                  int
+		code                    string
+	}{
+		{"/v1/models/m/batch", "text/plain", "0,0", 415, server.CodeUnsupportedType},
+		{"/v1/models/m/batch", "application/json", `{"input_x": [0, 0]}`, 400, server.CodeInvalidBody},
+		{"/v1/models/m/batch", "application/json", `[[0, 0], [0, 0`, 400, server.CodeInvalidBody},
+		{"/v1/models/m/batch", "application/json", `[[0, 0]] [[0, 0]]`, 400, server.CodeInvalidBody},
+		{"/v1/models/m/batch", "application/json", "[" + strings.Repeat(" ", 64<<20) + "]", 413, server.CodeBodyTooLarge},
+		{"/v1/models/m/batch", "text/csv", "e,x\n0,0\n", 400, server.CodeInvalidBody},
+		{"/v1/models/m/batch", "text/csv", "", 400, server.CodeInvalidBody},
+		{"/v1/models/m/batch?resolution=1", "application/json", `[[0, 0]]`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/batch?resolution=a", "application/json", `[[0, 0]]`, 400, server.CodeInvalidInputs},
+		{"/v1/models/x/batch", "application/json", `[[0, 0]]`, 404, server.CodeModelNotFound},
+	} {
+		status, _, body := postBatch(t, srv, c.path, c.contentType, c.body)
+		var resp server.ErrorResponse
+		if err := json.Unmarshal([]byte(body), &resp); err != nil || status != c.status || resp.Error.Code != c.code {
+			if len(body) > 200 {
+				body = body[:200]
+			}
+			t.Errorf("%v %v: %v %v", c.path, c.contentType, status, body)
+		}
+	}
+
+	// Many rows keep their order.
+	var in strings.Builder
+	in.WriteString("e,ec\n")
+	const n = 5000
+	for k := 0; k < n; k++ {
+		fmt.Fprintf(&in, "%v,%v\n", float64(k%61)/10-3, float64(k%17)/10-0.8)
+	}
+	status, _, body = postBatch(t, srv, "/v1/models/m/batch?resolution=100", "text/csv", in.String())
+	records, err = csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil || status != 200 || len(records) != n+1 {
+		t.Fatalf("large csv batch: %v %v %v rows", status, err, len(records))
+	}
+	for k := 0; k < n; k++ {
+		e, ec := float64(k%61)/10-3, float64(k%17)/10-0.8
+		if want := strconv.FormatFloat(expect(e, ec), 'g', -1, 64); records[k+1][2] != want {
+			t.Fatalf("large csv batch row %v: %v, expect %v", k, records[k+1], want)
+		}
+	}
+}
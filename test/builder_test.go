@@ -0,0 +1,57 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	fc, err := fuzzy.NewBuilder().
+		Input("e", -30, 30).
+		Term("ZO", fuzzy.Tri(-10, 0, 10)).
+		Term("NS", fuzzy.Trap(-5, 5.5, 10.2, 15)).
+		Term("PS", fuzzy.S(12.1, 20.2)).
+		Input("ec", -30.2, 30.5).
+		Term("ZO", fuzzy.Tri(-10, 0, 10.1)).
+		Term("NS", fuzzy.Trap(-5.1, 5, 10.1, 15)).
+		Term("PS", fuzzy.S(12.2, 20.1)).
+		Output("u", -20, 20).
+		Term("ZO", fuzzy.Tri(-10, 0, 17.1)).
+		Term("NS", fuzzy.Trap(-5, 5, 10.1, 15.2)).
+		Term("PS", fuzzy.S(12.2, 20.1)).
+		Rule([]string{"NS", "ZO"}, "PS").
+		Rule([]string{"ZO", "ZO"}, "ZO").
+		Rule([]string{"ZO", "PS"}, "NS").
+		Rule([]string{"PS", "PS"}, "NS").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := fc.ToJSON()
+	str, _ := want.ToJSON()
+	if got != str {
+		t.Errorf("built model differs from mamdaniModel.json:\n%v", got)
+	}
+
+	// The first mistake is reported by Build.
+	_, err = fuzzy.NewBuilder().
+		Input("e", -1, 1).
+		Term("ZO", fuzzy.Tri(1, 0, -1)).
+		Output("u", -1, 1).
+		Build()
+	if err == nil {
+		t.Error("expect error for unordered parameters, got none")
+	}
+	_, err = fuzzy.NewBuilder().
+		Input("e", -1, 1).Term("ZO", fuzzy.Tri(-1, 0, 1)).
+		Output("u", -1, 1).Term("ZO", fuzzy.Tri(-1, 0, 1)).
+		Rule([]string{"NB"}, "ZO").
+		Build()
+	if err == nil {
+		t.Error("expect error for unknown label, got none")
+	}
+}
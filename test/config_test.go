@@ -0,0 +1,83 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModelFormats(t *testing.T) {
+	for file, twin := range map[string]string{
+		"./mamdaniModel.yaml": "./mamdaniModel.json",
+		"./sugenoModel.toml":  "./sugenoModel.json",
+	} {
+		fc, err := fuzzy.LoadFuzzyController(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := fuzzy.LoadFuzzyController(twin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := fc.ToJSON()
+		str, _ := want.ToJSON()
+		if got != str {
+			t.Errorf("%v differs from %v:\n%v", file, twin, got)
+		}
+
+		// The same model without a known extension.
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "model.conf")
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fuzzy.LoadFuzzyController(path); err != nil {
+			t.Errorf("%v: format not detected: %v", file, err)
+		}
+	}
+
+	// Strict decoding applies to every format.
+	if _, err := fuzzy.NewFuzzyControllerFromYAML("system:\n  defuzMethod: centroid\n"); err == nil {
+		t.Error("expect error for unknown yaml field, got none")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for model, format := range map[string]string{
+		"  {\"system\": {}}":            fuzzy.FormatJSON,
+		"# comment\nsystem:\n  name: x": fuzzy.FormatYAML,
+		"# comment\n[system]\nname = 1": fuzzy.FormatTOML,
+		"Engine: x\n":                   fuzzy.FormatFLL,
+		// ':' in a toml value, '=' in a yaml value.
+		"name = \"a:b\"\n[system]":  fuzzy.FormatTOML,
+		"\"name\" = 'http://x'":     fuzzy.FormatTOML,
+		"name: a=b\nsystem:":        fuzzy.FormatYAML,
+		"system: {name: \"a = b\"}": fuzzy.FormatYAML,
+	} {
+		if got := fuzzy.DetectFormat(model); got != format {
+			t.Errorf("%q: expect %v, got %v", model, format, got)
+		}
+	}
+
+	// A toml model with ':' in a value, by its extension and by its
+	// content.
+	content, err := ioutil.ReadFile("./sugenoModel.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := strings.Replace(string(content), `name = "fuzzyModel"`, `name = "fuzzy:Model"`, 1)
+	for _, name := range []string{"model.toml", "model.conf"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := ioutil.WriteFile(path, []byte(model), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fuzzy.LoadFuzzyController(path); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"testing"
+)
+
+// The bisector of curves whose area lies at the right end, or
+// which have none, walks up to the last point without reading
+// past it.
+func TestBisectorLastPoint(t *testing.T) {
+	x := []float64{0, 1, 2, 3, 4}
+	for _, c := range []struct {
+		y      []float64
+		expect float64
+	}{
+		{[]float64{0, 0, 0, 0, 1}, 4},
+		{[]float64{0, 0, 0, 0, 0}, 4},
+		{[]float64{1, 1, 1, 1, 1}, 2},
+	} {
+		got, err := fuzzy.Bisector(x, c.y)
+		if err != nil || got != c.expect {
+			t.Errorf("bisector of %v: %v, %v, expect %v", c.y, got, err, c.expect)
+		}
+	}
+}
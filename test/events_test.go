@@ -0,0 +1,234 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	server "fuzzy/serverMod"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type sseMessage struct {
+	event, data string
+}
+
+// Reading the messages of an event stream.
+func readEvents(body *bufio.Reader, messages chan<- sseMessage) {
+	defer close(messages)
+	var msg sseMessage
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if msg.data != "" {
+				messages <- msg
+			}
+			msg = sseMessage{}
+		case strings.HasPrefix(line, "event: "):
+			msg.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			msg.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func subscribe(t *testing.T, srv *httptest.Server, model string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/v1/models/" + model + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET events: %v %v", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body := bufio.NewReader(resp.Body)
+	// The stream starts with the reconnect delay.
+	if line, err := body.ReadString('\n'); err != nil || !strings.HasPrefix(line, "retry: ") {
+		t.Fatalf("first line %q, %v", line, err)
+	}
+	return resp, body
+}
+
+func next(t *testing.T, messages <-chan sseMessage) sseMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("event stream closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return sseMessage{}
+}
+
+func TestEvents(t *testing.T) {
+	srv := httptest.NewServer(server.NewRouter(server.NewRegistry()))
+	defer srv.Close()
+	request(t, srv, "PUT", "/v1/models/m", readModel(t, "./mamdaniModel.json"))
+	request(t, srv, "PUT", "/v1/models/other", readModel(t, "./mamdaniModel.json"))
+	if status, _ := request(t, srv, "GET", "/v1/models/x/events", ""); status != 404 {
+		t.Errorf("events of an unknown model: %v", status)
+	}
+	schemas := openAPI(t)["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	resp, body := subscribe(t, srv, "m")
+	messages := make(chan sseMessage, 16)
+	go readEvents(body, messages)
+
+	request(t, srv, "POST", "/v1/models/other/calculate", `{"input_x": [0, 0]}`)
+	request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [2.13, 0.2]}`)
+	request(t, srv, "POST", "/models/m/calculate", `{"input_x": [0, 0]}`)
+	for _, input := range []float64{2.13, 0} {
+		msg := next(t, messages)
+		var v interface{}
+		if err := json.Unmarshal([]byte(msg.data), &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := conforms(schemas, schemas["EvaluationEvent"].(map[string]interface{}), v, "EvaluationEvent"); err != nil {
+			t.Error(err)
+		}
+		var event server.EvaluationEvent
+		if err := json.Unmarshal([]byte(msg.data), &event); err != nil {
+			t.Fatal(err)
+		}
+		if msg.event != "" || event.Model != "m" || event.Inputs["e"] != input || event.Outputs["u"] == nil ||
+			event.Fired == 0 || len(event.Rules) == 0 || len(event.Rules) > event.Fired || time.Since(event.Time) > time.Minute {
+			t.Errorf("event %v", msg.data)
+		}
+		for k := 1; k < len(event.Rules); k++ {
+			if event.Rules[k].Strength > event.Rules[k-1].Strength {
+				t.Errorf("rules not strongest first: %+v", event.Rules)
+			}
+		}
+	}
+	resp.Body.Close()
+}
+
+// A subscriber which does not read delays no evaluation, it misses
+// events and is told how many.
+func TestEventsBackpressure(t *testing.T) {
+	srv := httptest.NewServer(server.NewRouter(server.NewRegistry()))
+	defer srv.Close()
+	request(t, srv, "PUT", "/v1/models/m", readModel(t, "./mamdaniModel.json"))
+	resp, body := subscribe(t, srv, "m")
+	defer resp.Body.Close()
+
+	// More events than the socket buffers hold.
+	const n, workers = 24000, 8
+	var wg sync.WaitGroup
+	var done int64
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < n/workers; k++ {
+				if status, _ := request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [0, 0]}`); status != 200 {
+					t.Errorf("calculate: %v", status)
+					return
+				}
+				atomic.AddInt64(&done, 1)
+			}
+		}()
+	}
+	// Evaluations waiting for the subscriber would stall.
+	finished := make(chan struct{})
+	go func() { wg.Wait(); close(finished) }()
+	for last := int64(-1); ; {
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			if now := atomic.LoadInt64(&done); now != last {
+				last = now
+				continue
+			}
+			t.Fatalf("evaluations stalled after %v", last)
+		}
+		break
+	}
+
+	messages := make(chan sseMessage, 16)
+	go readEvents(body, messages)
+	// Every evaluation is either delivered or reported dropped. The
+	// last drops are reported with the next event, so once the
+	// stream is idle, one more evaluation is sent.
+	sent, delivered, dropped := n, 0, 0
+	for delivered+dropped < sent {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if msg.event != "dropped" {
+				delivered++
+				break
+			}
+			var d struct{ Dropped int }
+			if err := json.Unmarshal([]byte(msg.data), &d); err != nil || d.Dropped <= 0 {
+				t.Fatalf("dropped event %q", msg.data)
+			}
+			dropped += d.Dropped
+		case <-time.After(200 * time.Millisecond):
+			if sent > n+5 {
+				t.Fatalf("%v delivered and %v dropped of %v events", delivered, dropped, sent)
+			}
+			request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [0, 0]}`)
+			sent++
+		}
+	}
+	if delivered+dropped != sent || delivered == 0 {
+		t.Errorf("%v delivered and %v dropped of %v events", delivered, dropped, sent)
+	}
+	if _, metrics := request(t, srv, "GET", "/metrics", ""); dropped > 0 &&
+		!strings.Contains(metrics, fmt.Sprintf("fuzzy_events_dropped_total{model=\"m\"} %v\n", dropped)) {
+		t.Errorf("metrics without %v dropped events", dropped)
+	}
+	t.Logf("%v delivered, %v dropped", delivered, dropped)
+}
+
+// Open streams end with the shutdown instead of holding it up.
+func TestEventsShutdown(t *testing.T) {
+	reg := server.NewRegistry()
+	fc, err := fuzzy.NewFuzzyController(readModel(t, "./mamdaniModel.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.Put("m", fc)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, &http.Server{Handler: server.NewRouter(reg)}, ln, time.Minute) }()
+
+	resp, body := subscribe(t, &httptest.Server{URL: "http://" + ln.Addr().String()}, "m")
+	defer resp.Body.Close()
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("shutdown waits for the event stream")
+	}
+	if _, err := ioutil.ReadAll(body); err != nil {
+		t.Errorf("stream not closed cleanly: %v", err)
+	}
+}
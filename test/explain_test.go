@@ -0,0 +1,268 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	fuzzy "fuzzy/fuzzyMod"
+	server "fuzzy/serverMod"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplain(t *testing.T) {
+	fc, err := fuzzy.NewFuzzyController(readModel(t, "./mamdaniModel.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, inputs := range [][]float64{{2.13, 0.2}, {-40, 0}, {0, 0}} {
+		ex, err := fc.Explain(inputs, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := fc.Evaluate(inputs, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ex.Inputs) != len(fc.Inputs) || len(ex.Rules) != len(fc.Rules) || len(ex.Outputs) != len(fc.Outputs) {
+			t.Fatalf("explanation of %v: %+v", inputs, ex)
+		}
+		for i, in := range ex.Inputs {
+			if in.Value != inputs[i] || in.Clamped < fc.Inputs[i].Range[0] || in.Clamped > fc.Inputs[i].Range[1] ||
+				len(in.Memberships) != len(fc.Inputs[i].Mf) {
+				t.Errorf("input %v of %v: %+v", i, inputs, in)
+			}
+		}
+		fired := 0
+		for _, r := range ex.Rules {
+			if r.Strength == nil || *r.Strength < 0 || *r.Strength > 1 {
+				t.Fatalf("rule of %v: %+v", inputs, r)
+			}
+			if *r.Strength > 0 {
+				fired++
+			}
+		}
+		if fired != fc.Fired() {
+			t.Errorf("%v rules with a strength, %v fired", fired, fc.Fired())
+		}
+		o := ex.Outputs[0]
+		if (o.Value == nil) != math.IsNaN(out[0]) || o.Value != nil && *o.Value != out[0] || len(o.X) == 0 || len(o.Y) != len(o.X) {
+			t.Fatalf("output of %v: %+v", inputs, o)
+		}
+		// The value is the defuzzified curve.
+		y := make([]float64, len(o.Y))
+		for k, v := range o.Y {
+			if v == nil {
+				t.Fatalf("output of %v: undefined curve point %v", inputs, k)
+			}
+			y[k] = *v
+		}
+		if c, err := fuzzy.Centroid(o.X, y); o.Value != nil && (err != nil || c != *o.Value) {
+			t.Errorf("centroid of the curve %v, %v, expect %v", c, err, *o.Value)
+		}
+		// The trace is not changed by later evaluations.
+		fc.Evaluate([]float64{-20, 0.5}, 200)
+		for k := range y {
+			if *o.Y[k] != y[k] {
+				t.Fatal("the trace shares the curve with the model")
+			}
+		}
+	}
+
+	// Without any fired rule, no output value.
+	gap, err := fuzzy.NewBuilder().Name("gap").Method("sugeno").
+		Input("x", 0, 10).Term("A", fuzzy.Tri(0, 1, 2)).
+		Output("u", 0, 10).Term("C", fuzzy.Const(4)).
+		Rule([]string{"A"}, "C").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex, err := gap.Explain([]float64{5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Outputs[0].Value != nil || *ex.Rules[0].Strength != 0 || ex.Outputs[0].X != nil ||
+		len(ex.Outputs[0].Activations) != 1 || *ex.Outputs[0].Activations[0].Degree != 0 {
+		t.Errorf("explanation without a fired rule: %+v", ex)
+	}
+
+	// A trimf whose peak is its left foot is 0/0 there: the degree,
+	// the strength, the activation and the curve are undefined.
+	peak, err := fuzzy.NewBuilder().Name("peak").
+		Input("x", 0, 10).Term("A", fuzzy.Tri(0, 0, 5)).Term("B", fuzzy.Tri(0, 5, 10)).
+		Output("u", 0, 10).Term("C", fuzzy.Tri(2, 2, 8)).
+		Rule([]string{"A"}, "C").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex, err = peak.Explain([]float64{0}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := ex.Inputs[0].Memberships; m[0].Degree != nil || m[1].Degree == nil || *m[1].Degree != 0 ||
+		ex.Rules[0].Strength != nil || ex.Outputs[0].Activations[0].Degree != nil || ex.Outputs[0].Value != nil {
+		t.Errorf("explanation of 0/0: %+v", ex)
+	}
+	if _, err := json.Marshal(ex); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExplainSurfaceAPI(t *testing.T) {
+	router := server.NewRouter(server.NewRegistry())
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	model := readModel(t, "./mamdaniModel.json")
+	request(t, srv, "PUT", "/v1/models/m", model)
+	fc, err := fuzzy.NewFuzzyController(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := openAPI(t)["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	check := func(body, schema string) {
+		t.Helper()
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := conforms(schemas, schemas[schema].(map[string]interface{}), v, schema); err != nil {
+			t.Error(err)
+		}
+	}
+
+	status, body := request(t, srv, "POST", "/v1/models/m/explain", `{"input_x": [2.13, 0.2], "resolution": [100]}`)
+	var ex server.ExplainResponse
+	if err := json.Unmarshal([]byte(body), &ex); err != nil || status != 200 {
+		t.Fatalf("explain: %v %v", status, body)
+	}
+	check(body, "ExplainResponse")
+	expect, _ := fc.Explain([]float64{2.13, 0.2}, 100)
+	if ex.Model != "m" || ex.Method != "mamdani" || *ex.Outputs[0].Value != *expect.Outputs[0].Value ||
+		len(ex.Rules) != len(expect.Rules) || len(ex.Outputs[0].X) != len(expect.Outputs[0].X) {
+		t.Errorf("explain: %v", body)
+	}
+
+	// Undefined degrees are null rather than a failure to encode them.
+	peak, err := fuzzy.NewBuilder().Name("peak").
+		Input("x", 0, 10).Term("A", fuzzy.Tri(0, 0, 5)).
+		Output("u", 0, 10).Term("C", fuzzy.Tri(2, 2, 8)).
+		Rule([]string{"A"}, "C").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peakJSON, err := peak.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, srv, "PUT", "/v1/models/peak", peakJSON)
+	status, body = request(t, srv, "POST", "/v1/models/peak/explain", `{"input_x": [0], "resolution": [10]}`)
+	if status != 200 || !strings.Contains(body, `"degree":null`) || !strings.Contains(body, `"strength":null`) {
+		t.Errorf("explain of 0/0: %v %v", status, body)
+	}
+	check(body, "ExplainResponse")
+
+	// A 2-D surface, the values of e follow each other.
+	status, body = request(t, srv, "POST", "/v1/models/m/surface",
+		`{"axes": [{"input": "ec", "points": 3}, {"input": "e", "points": 5, "min": -10, "max": 10}], "resolution": [100]}`)
+	var surface server.SurfaceResponse
+	if err := json.Unmarshal([]byte(body), &surface); err != nil || status != 200 {
+		t.Fatalf("surface: %v %v", status, body)
+	}
+	check(body, "SurfaceResponse")
+	if len(surface.Axes) != 2 || len(surface.Axes[0].Values) != 3 || len(surface.Axes[1].Values) != 5 ||
+		surface.Axes[1].Values[0] != -10 || surface.Axes[1].Values[4] != 10 || len(surface.Outputs["u"]) != 15 || len(surface.Inputs) != 0 {
+		t.Fatalf("surface: %v", body)
+	}
+	if r := fc.Inputs[1].Range; surface.Axes[0].Values[0] != r[0] || surface.Axes[0].Values[2] != r[1] {
+		t.Errorf("axis ec %v, expect the range %v", surface.Axes[0].Values, r)
+	}
+	for a, ec := range surface.Axes[0].Values {
+		for b, e := range surface.Axes[1].Values {
+			out, err := fc.Evaluate([]float64{e, ec}, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := surface.Outputs["u"][a*5+b]; (v == nil) != math.IsNaN(out[0]) || v != nil && *v != out[0] {
+				t.Errorf("surface at e=%v ec=%v: %v, expect %v", e, ec, v, out[0])
+			}
+		}
+	}
+
+	// A 1-D surface, the other input fixed or in the middle.
+	for _, c := range []struct {
+		body string
+		ec   float64
+	}{
+		{`{"axes": [{"input": "e"}], "inputs": {"ec": 0.3}}`, 0.3},
+		{`{"axes": [{"input": "e"}]}`, (fc.Inputs[1].Range[0] + fc.Inputs[1].Range[1]) / 2},
+	} {
+		status, body = request(t, srv, "POST", "/v1/models/m/surface", c.body)
+		surface = server.SurfaceResponse{}
+		if err := json.Unmarshal([]byte(body), &surface); err != nil || status != 200 || len(surface.Outputs["u"]) != 21 {
+			t.Fatalf("surface %v: %v %v", c.body, status, body)
+		}
+		if surface.Inputs["ec"] != c.ec {
+			t.Errorf("surface %v: ec %v, expect %v", c.body, surface.Inputs["ec"], c.ec)
+		}
+		out, _ := fc.Evaluate([]float64{surface.Axes[0].Values[7], c.ec})
+		if v := surface.Outputs["u"][7]; (v == nil) != math.IsNaN(out[0]) || v != nil && *v != out[0] {
+			t.Errorf("surface %v: %v, expect %v", c.body, v, out[0])
+		}
+	}
+
+	for _, c := range []struct {
+		path, body string
+		status     int
+		code       string
+	}{
+		{"/v1/models/x/explain", `{"input_x": [0, 0]}`, 404, server.CodeModelNotFound},
+		{"/v1/models/m/explain", `{"input_x": [0]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/explain", `{"input": [0, 0]}`, 400, server.CodeInvalidBody},
+		{"/v1/models/x/surface", `{"axes": [{"input": "e"}]}`, 404, server.CodeModelNotFound},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e"}], "extra": 1}`, 400, server.CodeInvalidBody},
+		{"/v1/models/m/surface", `{"axes": []}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e"}, {"input": "ec"}, {"input": "e"}]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e"}, {"input": "e"}]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "x"}]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e", "points": 1}]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e", "min": 1, "max": 1}]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e", "points": 1000}, {"input": "ec", "points": 1000}]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e"}], "inputs": {"e": 0}}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e"}], "inputs": {"x": 0}}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e"}], "resolution": [1]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e", "points": 1001}, {"input": "ec", "points": 65}], "resolution": [1048576]}`, 400, server.CodeInvalidInputs},
+		{"/v1/models/m/surface", `{"axes": [{"input": "e", "points": 1001}, {"input": "ec", "points": 65}], "resolution": [1100]}`, 400, server.CodeInvalidInputs},
+	} {
+		status, body := request(t, srv, "POST", c.path, c.body)
+		var resp server.ErrorResponse
+		if err := json.Unmarshal([]byte(body), &resp); err != nil || status != c.status || resp.Error.Code != c.code {
+			t.Errorf("%v %v: %v %v", c.path, c.body, status, body)
+		}
+	}
+	_, metrics := request(t, srv, "GET", "/metrics", "")
+	for _, line := range []string{
+		`fuzzy_evaluation_errors_total{model="m",type="invalid_body"} 2`,
+		`fuzzy_evaluation_errors_total{model="m",type="invalid_inputs"} 13`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("metrics without %v", line)
+		}
+	}
+
+	// The surface of a client which left is not evaluated.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/v1/models/m/surface",
+		strings.NewReader(`{"axes": [{"input": "e", "points": 1001}, {"input": "ec", "points": 60}]}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(rec, req)
+	if rec.Body.Len() != 0 || time.Since(start) > 5*time.Second {
+		t.Errorf("surface after the client left: %v in %v", rec.Body.Len(), time.Since(start))
+	}
+}
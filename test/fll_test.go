@@ -0,0 +1,129 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFLLRoundTrip(t *testing.T) {
+	for _, file := range []string{"./mamdaniModel.json", "./sugenoModel.json"} {
+		jsonByte, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc, err := fuzzy.NewFuzzyController(string(jsonByte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fll, err := fc.ToFLL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := fuzzy.NewFuzzyControllerFromFLL(fll)
+		if err != nil {
+			t.Fatalf("%v: %v\n%v", file, err, fll)
+		}
+		again, err := back.ToFLL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != fll {
+			t.Errorf("%v: fll changed after round trip:\n%v\n%v", file, fll, again)
+		}
+
+		inputs := []float64{2.3, 0.1}
+		want := evaluate(t, &fc, inputs)
+		got := evaluate(t, &back, inputs)
+		for i := range want {
+			if math.Abs(want[i]-got[i]) > 1e-9 {
+				t.Errorf("%v: output %v, expect %v, got %v", file, i, want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestFLLParse(t *testing.T) {
+	fll := `Engine: tipper
+# comments and fuzzylite-only properties are ignored
+InputVariable: service
+  enabled: true
+  range: 0.000 10.000
+  lock-range: false
+  term: poor Gaussian 0.000 1.500
+  term: good Bell 5.000 2.000 3.000
+OutputVariable: tip
+  enabled: true
+  range: 0.000 30.000
+  lock-range: false
+  aggregation: Maximum
+  defuzzifier: Centroid 200
+  default: nan
+  lock-previous: false
+  term: cheap Triangle 0.000 5.000 10.000 1.0
+  term: generous Triangle 20.000 25.000 30.000
+RuleBlock: mamdani
+  enabled: true
+  conjunction: Minimum
+  disjunction: Maximum
+  implication: Minimum
+  activation: General
+  rule: if service is poor then tip is cheap
+  rule: if service is good then tip is generous with 1.0
+`
+	fc, err := fuzzy.NewFuzzyControllerFromFLL(fll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc.System.Method != "mamdani" || fc.System.Defuzzmethod != "centroid" {
+		t.Errorf("expect mamdani/centroid, got %v/%v", fc.System.Method, fc.System.Defuzzmethod)
+	}
+	bell := fc.Inputs[0].Mf[1]
+	if bell.Type != "gbellmf" || bell.Params[0] != 2 || bell.Params[1] != 3 || bell.Params[2] != 5 {
+		t.Errorf("unexpected bell term %+v", bell)
+	}
+	if len(fc.Rules) != 2 || fc.Rules[1].Consequent[0] != "generous" {
+		t.Errorf("unexpected rules %+v", fc.Rules)
+	}
+
+	for _, bad := range []string{
+		"  rule: if service is very poor then tip is cheap\n",
+		"  rule: if service is poor then tip is cheap with 0.5\n",
+		"  rule: if waiter is poor then tip is cheap\n",
+		"  term: odd Ramp 0.000 1.000\n",
+		"  activation: Highest 1\n",
+		"  activation: Threshold >= 0.5\n",
+	} {
+		if _, err := fuzzy.NewFuzzyControllerFromFLL(fll + bad); err == nil {
+			t.Errorf("expect error for %q, got none", bad)
+		}
+	}
+	// Activations other than General are refused, not evaluated as
+	// General.
+	highest := strings.Replace(fll, "activation: General", "activation: Highest 2", 1)
+	if _, err := fuzzy.NewFuzzyControllerFromFLL(highest); err == nil || !strings.Contains(err.Error(), "error by activation") {
+		t.Errorf("expect an error by activation, got %v", err)
+	}
+}
+
+func evaluate(t *testing.T, fc *fuzzy.FuzzyController, inputs []float64) []float64 {
+	if err := fc.SetInputs(inputs); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if fc.System.Method == "mamdani" {
+		err = fc.AggregateMamdani([]int{200})
+	} else {
+		err = fc.AggregateSugeno()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	rst, err := fc.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rst
+}
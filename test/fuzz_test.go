@@ -56,3 +56,32 @@ func TestDefuzz(t *testing.T) {
 	}
 	t.Log(fuzzy.Centroid(x, y))
 }
+
+// Invalid resolutions are refused, also by a fresh fuzzyController
+// without any cached grid.
+func TestResolution(t *testing.T) {
+	model := readModel(t, "./mamdaniModel.json")
+	for _, res := range []int{0, 1, -5} {
+		fc, err := fuzzy.NewFuzzyController(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err := fc.Evaluate([]float64{2.13, 0.2}, res); err == nil {
+			t.Errorf("resolution %v: %v, expect an error", res, out)
+		}
+		fc.SetInputs([]float64{2.13, 0.2})
+		if err := fc.AggregateMamdani([]int{res}); err == nil {
+			t.Errorf("resolution %v aggregated", res)
+		}
+	}
+	fc, err := fuzzy.NewFuzzyController(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fc.Evaluate([]float64{2.13, 0.2}, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fc.Evaluate([]float64{2.13, 0.2}, 0); err == nil {
+		t.Error("resolution 0 after 100 evaluated")
+	}
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Generating the Go code of the models and running the generated
+// tests, which compare Eval with the interpreted controller.
+func TestGenerateGo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go tool")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	for _, c := range []struct {
+		file   string
+		defuzz string
+	}{
+		{"./mamdaniModel.json", "centroid"},
+		{"./mamdaniModel.json", "bisector"},
+		{"./mamdaniModel.json", "mom"},
+		{"./sugenoModel.json", "wtaver"},
+		{"./sugenoModel.json", "wtsum"},
+	} {
+		fc, err := fuzzy.LoadFuzzyController(c.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc.System.Defuzzmethod = c.defuzz
+		src, test, err := fc.GenerateGo(fuzzy.GenOptions{Resolution: []int{300}, Samples: 32})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir := t.TempDir()
+		for name, content := range map[string][]byte{
+			"go.mod":             []byte("module fuzzymodel\n\ngo 1.16\n"),
+			"fuzzymodel.go":      src,
+			"fuzzymodel_test.go": test,
+		} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cmd := exec.Command(goTool, "test", ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%v/%v: generated test failed: %v\n%s", c.file, c.defuzz, err, out)
+		}
+	}
+}
+
+// Compiling the C code of the models in double and float and
+// running the generated test program against the golden vectors.
+// The files are named after opts.Name, which is no C identifier.
+func TestGenerateC(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the C compiler")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not found")
+	}
+	for _, c := range []struct {
+		file   string
+		defuzz string
+	}{
+		{"./mamdaniModel.json", "centroid"},
+		{"./mamdaniModel.json", "bisector"},
+		{"./mamdaniModel.json", "lom"},
+		{"./sugenoModel.json", "wtaver"},
+		{"./sugenoModel.json", "wtsum"},
+	} {
+		fc, err := fuzzy.LoadFuzzyController(c.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc.System.Defuzzmethod = c.defuzz
+		header, src, test, err := fc.GenerateC(fuzzy.GenOptions{Name: "Fuzzy-Model", Resolution: []int{300}, Samples: 32})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir := t.TempDir()
+		for name, content := range map[string][]byte{
+			"Fuzzy-Model.h":      header,
+			"Fuzzy-Model.c":      src,
+			"Fuzzy-Model_test.c": test,
+		} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, float := range []string{"0", "1"} {
+			cmd := exec.Command(cc, "-std=c99", "-Wall", "-Wextra", "-Werror", "-pedantic",
+				"-DFUZZY_MODEL_USE_FLOAT="+float, "-o", "fuzzymodel_test",
+				"Fuzzy-Model.c", "Fuzzy-Model_test.c", "-lm")
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("%v/%v: compilation failed: %v\n%s", c.file, c.defuzz, err, out)
+			}
+			cmd = exec.Command(filepath.Join(dir, "fuzzymodel_test"))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%v/%v (float %v): generated test failed: %v\n%s", c.file, c.defuzz, float, err, out)
+			}
+		}
+	}
+}
+
+// The test program checks opts.Samples golden vectors, 64 by
+// default.
+func TestGenerateCSamples(t *testing.T) {
+	fc, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for samples, n := range map[int]int{5: 5, 0: 64, 100: 100} {
+		_, _, test, err := fc.GenerateC(fuzzy.GenOptions{Resolution: []int{100}, Samples: samples})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			fmt.Sprintf("vectors_in[%v][2] = {", n),
+			fmt.Sprintf("vectors_out[%v][1] = {", n),
+			fmt.Sprintf("for (k = 0; k < %v; k++)", n),
+		} {
+			if !strings.Contains(string(test), want) {
+				t.Errorf("samples %v: missing %q in\n%s", samples, want, test)
+			}
+		}
+	}
+}
+
+// The Structured Text cannot be run here, checking the interface
+// and the structure of the function blocks instead.
+func TestGenerateST(t *testing.T) {
+	for _, c := range []struct {
+		file   string
+		defuzz string
+		float  bool
+	}{
+		{"./mamdaniModel.json", "centroid", false},
+		{"./mamdaniModel.json", "bisector", true},
+		{"./mamdaniModel.json", "mom", false},
+		{"./sugenoModel.json", "wtaver", false},
+	} {
+		fc, err := fuzzy.LoadFuzzyController(c.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc.System.Defuzzmethod = c.defuzz
+		src, err := fc.GenerateST(fuzzy.GenOptions{Name: "FB_Fuzzy", Float: c.float})
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := string(src)
+		real := "LREAL"
+		if c.float {
+			real = "REAL"
+		}
+		for _, want := range []string{
+			"FUNCTION_BLOCK FB_Fuzzy\nVAR_INPUT\n",
+			"\te : " + real + ";",
+			"\tec : " + real + ";",
+			"VAR_OUTPUT\n\tu : " + real + ";",
+			"\nu := ",
+			"END_FUNCTION_BLOCK\n",
+		} {
+			if !strings.Contains(st, want) {
+				t.Errorf("%v/%v: missing %q in\n%v", c.file, c.defuzz, want, st)
+			}
+		}
+		for open, end := range map[string]string{"IF ": "END_IF;", "FOR ": "END_FOR;", "WHILE ": "END_WHILE;"} {
+			opened, ended := 0, 0
+			for _, line := range strings.Split(st, "\n") {
+				line = strings.TrimSpace(line)
+				if strings.HasPrefix(line, open) {
+					opened++
+				}
+				if line == end {
+					ended++
+				}
+			}
+			if opened != ended {
+				t.Errorf("%v/%v: %v %q and %v %q", c.file, c.defuzz, opened, open, ended, end)
+			}
+		}
+	}
+
+	fc, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"fuzzy model", "IF", "fz_block", "Case", "FUNCTION_BLOCK", "VAR_TEMP", "true", "Dint", "REPEAT", "exit"} {
+		if _, err := fc.GenerateST(fuzzy.GenOptions{Name: name}); err == nil {
+			t.Errorf("GenerateST with name %q: expect an error", name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"math"
+	"sync"
+	"testing"
+)
+
+// Evaluations running while the model is swapped get the outputs
+// of either model, never a mix of both.
+func TestHandleSwap(t *testing.T) {
+	var models []fuzzy.FuzzyController
+	for _, file := range []string{"./mamdaniModel.json", "./sugenoModel.json"} {
+		fc, err := fuzzy.LoadFuzzyController(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		models = append(models, fc)
+	}
+	inputs := [][]float64{{-12, 3}, {0, 0}, {7.5, -20}, {25, 14}}
+	want := make([][]uint64, len(inputs))
+	for k, in := range inputs {
+		for _, fc := range models {
+			out, err := fc.Evaluate(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want[k] = append(want[k], math.Float64bits(out[0]))
+		}
+	}
+
+	h, err := fuzzy.NewHandle(models[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := make([]float64, 1)
+			for k := 0; ; k = (k + 1) % len(inputs) {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := h.EvaluateTo(out, inputs[k]); err != nil {
+					t.Error(err)
+					return
+				}
+				if bits := math.Float64bits(out[0]); bits != want[k][0] && bits != want[k][1] {
+					t.Errorf("Evaluate(%v) = %v, expect %v or %v", inputs[k], out[0],
+						math.Float64frombits(want[k][0]), math.Float64frombits(want[k][1]))
+					return
+				}
+			}
+		}()
+	}
+	for n := 0; n < 200; n++ {
+		if err := h.Swap(models[(n+1)%2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	// An invalid model is refused, the handle keeps the last one.
+	invalid := models[0]
+	invalid.System.Andmethod = "avg"
+	if err := h.Swap(invalid); err == nil {
+		t.Error("Swap with an invalid model: expect an error")
+	}
+	if got := h.Model(); got.System.Method != models[0].System.Method {
+		t.Errorf("model %q after the refused swap, expect %q", got.System.Method, models[0].System.Method)
+	}
+	out, err := h.Evaluate(inputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Float64bits(out[0]) != want[0][0] {
+		t.Errorf("Evaluate(%v) = %v, expect %v", inputs[0], out[0], math.Float64frombits(want[0][0]))
+	}
+}
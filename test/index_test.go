@@ -0,0 +1,132 @@
+package test
+
+import (
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// A large model of 5 inputs with 7 triangular labels each, few
+// labels of every input are active at a time.
+func largeModel(t testing.TB, method, and, or string, rules int, dsig bool) fuzzy.FuzzyController {
+	rnd := rand.New(rand.NewSource(7))
+	b := fuzzy.NewBuilder().Method(method).And(and).Or(or)
+	labels := make([]string, 7)
+	for i := 0; i < 5; i++ {
+		b.Input(fmt.Sprintf("x%v", i), 0, 6)
+		for j := range labels {
+			labels[j] = fmt.Sprintf("L%v", j)
+			b.Term(labels[j], fuzzy.Tri(float64(j)-1, float64(j), float64(j)+1))
+		}
+	}
+	if dsig {
+		// Dips below 0 between the centres.
+		b.Term("D", fuzzy.Dsig(1, 4, 3, 1))
+	}
+	for o := 0; o < 2; o++ {
+		b.Output(fmt.Sprintf("u%v", o), -10, 10)
+		for j := 0; j < 5; j++ {
+			if method == "sugeno" {
+				b.Term(fmt.Sprintf("C%v", j), fuzzy.Const(-10+5*float64(j)))
+			} else {
+				b.Term(fmt.Sprintf("C%v", j), fuzzy.Tri(-15+5*float64(j), -10+5*float64(j), -5+5*float64(j)))
+			}
+		}
+	}
+	for n := 0; n < rules; n++ {
+		antecedent := make([]string, 5)
+		for i := range antecedent {
+			antecedent[i] = labels[rnd.Intn(len(labels))]
+		}
+		if dsig && n%5 == 0 {
+			antecedent[4] = "D"
+		}
+		consequent := []string{fmt.Sprintf("C%v", rnd.Intn(5)), fmt.Sprintf("C%v", rnd.Intn(5))}
+		if n%10 == 0 {
+			b.OrRule(antecedent, consequent...)
+		} else {
+			b.Rule(antecedent, consequent...)
+		}
+	}
+	fc, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fc
+}
+
+// The indexed evaluation gives bit for bit the results of the
+// full scan.
+func TestRuleIndex(t *testing.T) {
+	for _, c := range []struct {
+		method, and, or string
+		dsig            bool
+	}{
+		{"mamdani", "min", "max", false},
+		{"mamdani", "prod", "probor", false},
+		{"sugeno", "min", "max", false},
+		{"sugeno", "prod", "sum", false},
+		{"sugeno", "prod", "probor", true},
+		{"mamdani", "min", "sum", true},
+	} {
+		full := largeModel(t, c.method, c.and, c.or, 3000, c.dsig)
+		indexed, err := full.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexed.SetRuleIndex(true)
+		if clone, _ := indexed.Clone(); !clone.RuleIndex() {
+			t.Errorf("%v: index lost by Clone", c)
+		}
+
+		rnd := rand.New(rand.NewSource(1))
+		for k := 0; k < 300; k++ {
+			in := make([]float64, 5)
+			for i := range in {
+				switch k % 3 {
+				case 0:
+					in[i] = 6 * rnd.Float64()
+				case 1:
+					// On the peaks, a single label is active.
+					in[i] = float64(rnd.Intn(7))
+				default:
+					in[i] = -1 + 8*rnd.Float64()
+				}
+			}
+			if k == 299 {
+				in[2] = math.NaN()
+			}
+			want, err := full.Evaluate(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := indexed.Evaluate(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for o := range want {
+				if math.Float64bits(got[o]) != math.Float64bits(want[o]) {
+					t.Errorf("%v: Evaluate(%v)[%v] = %v, full scan %v", c, in, o, got[o], want[o])
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkRuleIndex(b *testing.B) {
+	fc := largeModel(b, "sugeno", "min", "max", 3000, false)
+	in := []float64{1.3, 2.7, 4.1, 0.4, 5.5}
+	for _, on := range []bool{false, true} {
+		fc.SetRuleIndex(on)
+		b.Run(fmt.Sprintf("index=%v", on), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := fc.Evaluate(in); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"encoding/json"
+	fuzzy "fuzzy/fuzzyMod"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLUT(t *testing.T) {
+	for _, file := range []string{"./mamdaniModel.json", "./sugenoModel.json"} {
+		fc, err := fuzzy.LoadFuzzyController(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := fc.CompileLUT([]int{25, 31}, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(table.Values) != 25*31 || len(table.MaxError) != 1 {
+			t.Fatalf("%v: %v values and %v errors", file, len(table.Values), len(table.MaxError))
+		}
+
+		// The grid points are exact.
+		for _, in := range [][]float64{{-30, -30.2}, {30, 30.5}, {-30 + 60./24*7, -30.2 + 60.7/30*11}} {
+			got, err := table.Eval(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := fc.Evaluate(in, 200)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !closeOrNaN(got[0], want[0], 1e-9) {
+				t.Errorf("%v: Eval(%v) = %v, expect %v", file, in, got[0], want[0])
+			}
+		}
+
+		// The cell centres are within the reported error.
+		for _, in := range [][]float64{{-30 + 60./24*10.5, -30.2 + 60.7/30*13.5}, {-30 + 60./24*12.5, -30.2 + 60.7/30*15.5}} {
+			got, err := table.Eval(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := fc.Evaluate(in, 200)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.IsNaN(got[0]) || math.Abs(got[0]-want[0]) > table.MaxError[0] {
+				t.Errorf("%v: Eval(%v) = %v, expect %v within %v", file, in, got[0], want[0], table.MaxError[0])
+			}
+		}
+
+		// Both encodings give the identical table.
+		b, err := json.Marshal(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON fuzzy.LUT
+		if err := json.Unmarshal(b, &fromJSON); err != nil {
+			t.Fatal(err)
+		}
+		bin, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromBinary fuzzy.LUT
+		if err := fromBinary.UnmarshalBinary(bin); err != nil {
+			t.Fatal(err)
+		}
+		for name, back := range map[string]*fuzzy.LUT{"json": &fromJSON, "binary": &fromBinary} {
+			if !sameLUT(table, back) {
+				t.Errorf("%v: table changed by the %v encoding", file, name)
+			}
+		}
+		if err := fromBinary.UnmarshalBinary(bin[:len(bin)-1]); err == nil {
+			t.Errorf("%v: truncated table decoded", file)
+		}
+	}
+}
+
+// The interpolation stays within the reported error where the
+// engine is smooth, checked on the sugeno model with a
+// membership function that has no kinks.
+func TestLUTMaxError(t *testing.T) {
+	fc, err := fuzzy.NewBuilder().Method("sugeno").
+		Input("x", -5, 5).Term("N", fuzzy.Sig(0, -1)).Term("P", fuzzy.Sig(0, 1)).
+		Input("y", 0, 10).Term("L", fuzzy.Gauss(0, 4)).Term("H", fuzzy.Gauss(10, 4)).
+		Output("u", -10, 10).Term("A", fuzzy.Const(-8)).Term("B", fuzzy.Const(3)).Term("C", fuzzy.Const(9)).
+		Rule([]string{"N", "L"}, "A").Rule([]string{"P", "L"}, "B").Rule([]string{"N", "H"}, "C").Rule([]string{"P", "H"}, "A").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := fc.CompileLUT([]int{41, 41})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.MaxError[0] <= 0 || table.MaxError[0] > 0.1 || table.Undefined[0] != 0 {
+		t.Fatalf("max error %v, %v undefined", table.MaxError[0], table.Undefined[0])
+	}
+	rnd := rand.New(rand.NewSource(1))
+	out := make([]float64, 1)
+	for k := 0; k < 1000; k++ {
+		in := []float64{-5 + 10*rnd.Float64(), 10 * rnd.Float64()}
+		if err := table.EvalTo(out, in); err != nil {
+			t.Fatal(err)
+		}
+		want, err := fc.Evaluate(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Twice the error at the centres bounds the error inside
+		// the cells of such a smooth surface.
+		if diff := math.Abs(out[0] - want[0]); diff > 2*table.MaxError[0] {
+			t.Errorf("Eval(%v) = %v, expect %v, beyond the max error %v", in, out[0], want[0], table.MaxError[0])
+		}
+	}
+	if n := testing.AllocsPerRun(100, func() { table.EvalTo(out, []float64{1, 2}) }); n != 0 {
+		t.Errorf("EvalTo allocates %v times", n)
+	}
+}
+
+func closeOrNaN(a, b, tol float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) <= tol*math.Max(1, math.Abs(b))
+}
+
+func sameLUT(a, b *fuzzy.LUT) bool {
+	if a.Name != b.Name || len(a.Inputs) != len(b.Inputs) || len(a.Outputs) != len(b.Outputs) ||
+		len(a.Values) != len(b.Values) || len(a.MaxError) != len(b.MaxError) || len(a.Undefined) != len(b.Undefined) {
+		return false
+	}
+	for i := range a.Inputs {
+		if a.Inputs[i] != b.Inputs[i] {
+			return false
+		}
+	}
+	for i := range a.Outputs {
+		if a.Outputs[i] != b.Outputs[i] {
+			return false
+		}
+	}
+	for i := range a.Values {
+		if !closeOrNaN(a.Values[i], b.Values[i], 0) {
+			return false
+		}
+	}
+	for i := range a.MaxError {
+		if a.MaxError[i] != b.MaxError[i] || a.Undefined[i] != b.Undefined[i] {
+			return false
+		}
+	}
+	return true
+}
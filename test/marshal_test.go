@@ -0,0 +1,60 @@
+package test
+
+import (
+	"encoding/json"
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, file := range []string{"./mamdaniModel.json", "./sugenoModel.json"} {
+		jsonByte, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc, err := fuzzy.NewFuzzyController(string(jsonByte))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The written document carries every field of the model file.
+		str, err := fc.ToJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want, got interface{}
+		if err := json.Unmarshal(jsonByte, &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(str), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%v: model changed after marshalling:\n%v", file, str)
+		}
+
+		// Saving and loading again gives the identical document.
+		path := filepath.Join(t.TempDir(), "model.json")
+		if err := fc.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		saved, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := fuzzy.NewFuzzyController(string(saved))
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := back.ToJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != str {
+			t.Errorf("%v: output not stable:\n%v\n%v", file, str, again)
+		}
+	}
+}
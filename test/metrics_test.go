@@ -0,0 +1,80 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	server "fuzzy/serverMod"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	store, err := server.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := server.NewRegistry()
+	reg.SetStore(store)
+	srv := httptest.NewServer(server.NewRouter(reg))
+	defer srv.Close()
+
+	gap, err := fuzzy.NewBuilder().Name("gap").Method("sugeno").
+		Input("x", 0, 10).Term("A", fuzzy.Tri(0, 1, 2)).
+		Output("u", 0, 10).Term("C", fuzzy.Const(4)).
+		Rule([]string{"A"}, "C").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gapJSON, err := gap.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	request(t, srv, "PUT", "/v1/models/m", readModel(t, "./mamdaniModel.json"))
+	request(t, srv, "PUT", "/v1/models/gap", gapJSON)
+	request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [0, 0]}`)
+	request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [0, 0]}`)
+	request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [0]}`)
+	request(t, srv, "POST", "/v1/models/m/calculate", `{"input_x": [0, 0`)
+	request(t, srv, "POST", "/v1/models/gap/calculate", `{"input_x": [5]}`)
+	request(t, srv, "POST", "/models/gap/calculate", `{"input_x": [5]}`)
+	request(t, srv, "POST", "/v1/models/unknown/calculate", `{"input_x": [5]}`)
+	request(t, srv, "GET", "/nothing", "")
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reg.Reload()
+
+	status, body := request(t, srv, "GET", "/metrics", "")
+	if status != 200 {
+		t.Fatalf("GET /metrics: %v %v", status, body)
+	}
+	for _, line := range []string{
+		`# TYPE fuzzy_http_requests_total counter`,
+		`fuzzy_http_requests_total{code="200",method="POST",model="m",route="/v1/models/{name}/calculate"} 2`,
+		`fuzzy_http_requests_total{code="400",method="POST",model="m",route="/v1/models/{name}/calculate"} 2`,
+		`fuzzy_http_requests_total{code="201",method="PUT",model="gap",route="/v1/models/{name}"} 1`,
+		// Unknown models and routes do not add series of their own.
+		`fuzzy_http_requests_total{code="404",method="POST",model="",route="/v1/models/{name}/calculate"} 1`,
+		`fuzzy_http_requests_total{code="404",method="GET",model="",route="unmatched"} 1`,
+		`# TYPE fuzzy_http_request_duration_seconds histogram`,
+		`fuzzy_http_request_duration_seconds_bucket{le="+Inf",model="m",route="/v1/models/{name}/calculate"} 4`,
+		`fuzzy_http_request_duration_seconds_count{model="m",route="/v1/models/{name}/calculate"} 4`,
+		`fuzzy_evaluation_errors_total{model="m",type="invalid_body"} 1`,
+		`fuzzy_evaluation_errors_total{model="m",type="invalid_inputs"} 1`,
+		`fuzzy_no_rule_fired_total{model="gap"} 2`,
+		`fuzzy_model_reloads_total{result="failed"} 1`,
+		`fuzzy_model_reloads_total{result="ok"} 0`,
+		`fuzzy_models 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics without %q:\n%v", line, body)
+		}
+	}
+	if strings.Contains(body, "unknown") {
+		t.Errorf("metrics with a series of an unknown model:\n%v", body)
+	}
+}
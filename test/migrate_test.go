@@ -0,0 +1,60 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigration(t *testing.T) {
+	legacy, err := ioutil.ReadFile("./legacyModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 1 models are migrated in memory.
+	fc, err := fuzzy.NewFuzzyController(string(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc.Version != fuzzy.ModelVersion || fc.System.Defuzzmethod != "centroid" {
+		t.Errorf("model not migrated: version %v, defuzzMethod %v", fc.Version, fc.System.Defuzzmethod)
+	}
+
+	// Version 2 only accepts lower case keywords, and newer
+	// versions are rejected.
+	v2 := strings.Replace(string(legacy), "{", `{"version": 2,`, 1)
+	if _, err := fuzzy.NewFuzzyController(v2); err == nil {
+		t.Error("expect error for upper case keywords in version 2, got none")
+	}
+	v3 := strings.Replace(string(legacy), "{", `{"version": 3,`, 1)
+	if _, err := fuzzy.NewFuzzyController(v3); err == nil {
+		t.Error("expect error for version 3, got none")
+	}
+
+	// Rewriting the file.
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := ioutil.WriteFile(path, legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := fuzzy.ModelFileVersion(path); err != nil || version != 1 {
+		t.Fatalf("expect version 1, got %v (%v)", version, err)
+	}
+	for _, expect := range []bool{true, false} {
+		rewritten, err := fuzzy.MigrateModelFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rewritten != expect {
+			t.Errorf("expect rewritten %v, got %v", expect, rewritten)
+		}
+	}
+	if version, err := fuzzy.ModelFileVersion(path); err != nil || version != fuzzy.ModelVersion {
+		t.Errorf("expect version %v, got %v (%v)", fuzzy.ModelVersion, version, err)
+	}
+	if _, err := fuzzy.ModelFileVersion("../model.schema.json"); err != fuzzy.ErrNotModel {
+		t.Errorf("expect ErrNotModel for the schema, got %v", err)
+	}
+}
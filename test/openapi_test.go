@@ -0,0 +1,257 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	server "fuzzy/serverMod"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// The document describes exactly the routes of the router.
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := openAPI(t)
+	paths := doc["paths"].(map[string]interface{})
+
+	var missing []string
+	routes := map[string]bool{}
+	err := server.NewRouter(server.NewRegistry()).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil // the /v1 prefix
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		routes[path] = true
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			missing = append(missing, path)
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = nil // any method, one operation at least
+			if len(operations(item)) == 0 {
+				missing = append(missing, path)
+			}
+		}
+		for _, m := range methods {
+			if _, ok := item[strings.ToLower(m)]; !ok {
+				missing = append(missing, m+" "+path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) > 0 {
+		t.Errorf("routes not in the document: %v", missing)
+	}
+
+	router := server.NewRouter(server.NewRegistry())
+	for path, item := range paths {
+		if !routes[path] {
+			t.Errorf("%v: no such route", path)
+			continue
+		}
+		for _, method := range operations(item.(map[string]interface{})) {
+			var match mux.RouteMatch
+			req := httptest.NewRequest(strings.ToUpper(method), strings.Replace(path, "{name}", "m", 1), nil)
+			if !router.Match(req, &match) || match.MatchErr != nil {
+				t.Errorf("%v %v: no such route", method, path)
+			}
+		}
+	}
+}
+
+// Responses of the server conform to the schemas of the document.
+func TestOpenAPISchemas(t *testing.T) {
+	doc := openAPI(t)
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	srv := httptest.NewServer(server.NewRouter(server.NewRegistry()))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		method, path, body string
+		schema             string
+	}{
+		{"PUT", "/v1/models/m", readModel(t, "./mamdaniModel.json"), "ModelSummary"},
+		{"PUT", "/v1/models/s", readModel(t, "./sugenoModel.json"), "ModelSummary"},
+		{"GET", "/v1/models", "", "ModelList"},
+		{"GET", "/v1/models/m", "", "Model"},
+		{"GET", "/v1/models/s", "", "Model"},
+		{"POST", "/v1/models/m/calculate", readModel(t, "../Inputs.json"), "CalculateResponse"},
+		{"POST", "/v1/models/s/calculate", `{"input_x": [0, 0]}`, "CalculateResponse"},
+		{"GET", "/v1/reload", "", "ReloadStatus"},
+		{"GET", "/v1/models/x", "", "ErrorResponse"},
+		{"POST", "/v1/models/m/calculate", `{"input_x": [0]}`, "ErrorResponse"},
+	} {
+		_, body := request(t, srv, c.method, c.path, c.body)
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			t.Errorf("%v %v: %v", c.method, c.path, err)
+			continue
+		}
+		if err := conforms(schemas, schemas[c.schema].(map[string]interface{}), v, c.schema); err != nil {
+			t.Errorf("%v %v: %v\n%v", c.method, c.path, err, body)
+		}
+	}
+
+	// The request bodies the server accepts, too.
+	var inputs interface{}
+	if err := json.Unmarshal([]byte(readModel(t, "../Inputs.json")), &inputs); err != nil {
+		t.Fatal(err)
+	}
+	if err := conforms(schemas, schemas["Inputs"].(map[string]interface{}), inputs, "Inputs"); err != nil {
+		t.Error(err)
+	}
+	if err := conforms(schemas, schemas["Inputs"].(map[string]interface{}), map[string]interface{}{"input_x": []interface{}{1.0}, "resolution": []interface{}{1.0}}, "Inputs"); err == nil {
+		t.Error("resolution 1 conforms to Inputs")
+	}
+}
+
+func openAPI(t *testing.T) map[string]interface{} {
+	t.Helper()
+	srv := httptest.NewServer(server.NewRouter(server.NewRegistry()))
+	defer srv.Close()
+	status, body := request(t, srv, "GET", "/openapi.json", "")
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil || status != 200 {
+		t.Fatalf("GET /openapi.json: %v %v", status, err)
+	}
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("openapi version %v", doc["openapi"])
+	}
+	if status, body := request(t, srv, "GET", "/docs", ""); status != 200 || !strings.Contains(body, "openapi.json") {
+		t.Errorf("GET /docs: %v", status)
+	}
+	return doc
+}
+
+func operations(item map[string]interface{}) []string {
+	var ops []string
+	for _, m := range []string{"get", "put", "post", "delete", "patch", "head", "options"} {
+		if _, ok := item[m]; ok {
+			ops = append(ops, m)
+		}
+	}
+	return ops
+}
+
+// Checking a decoded json value against the keywords of the schemas
+// used by the document.
+func conforms(schemas, schema map[string]interface{}, v interface{}, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		return conforms(schemas, schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{}), v, at)
+	}
+	var types []string
+	switch typ := schema["type"].(type) {
+	case string:
+		types = []string{typ}
+	case []interface{}:
+		for _, t := range typ {
+			types = append(types, t.(string))
+		}
+	}
+	if len(types) > 0 && !hasType(types, v) {
+		return fmt.Errorf("%v: %v is no %v", at, v, types)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			return fmt.Errorf("%v: %v not in %v", at, v, enum)
+		}
+	}
+	if n, ok := v.(float64); ok {
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("%v: %v below %v", at, n, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && n > max {
+			return fmt.Errorf("%v: %v above %v", at, n, max)
+		}
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, e := range v {
+				if err := conforms(schemas, items, e, fmt.Sprintf("%v[%v]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if _, ok := v[r.(string)]; !ok {
+				return fmt.Errorf("%v: no %v", at, r)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, ok := props[k].(map[string]interface{})
+			if !ok {
+				switch extra := schema["additionalProperties"].(type) {
+				case bool:
+					if !extra {
+						return fmt.Errorf("%v: unknown property %v", at, k)
+					}
+					continue
+				case map[string]interface{}:
+					sub = extra
+				default:
+					continue
+				}
+			}
+			if err := conforms(schemas, sub, v[k], at+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasType(types []string, v interface{}) bool {
+	for _, typ := range types {
+		switch v := v.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case float64:
+			if typ == "number" || typ == "integer" && v == float64(int64(v)) {
+				return true
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
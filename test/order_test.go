@@ -0,0 +1,70 @@
+package test
+
+import (
+	fuzzy "fuzzy/fuzzyMod"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Identical inputs give bit-identical outputs, whichever controller
+// evaluates them and whatever it evaluated before.
+func TestDeterministicOutputs(t *testing.T) {
+	var models []fuzzy.FuzzyController
+	for _, file := range []string{"./mamdaniModel.json", "./sugenoModel.json"} {
+		fc, err := fuzzy.LoadFuzzyController(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		models = append(models, fc)
+	}
+	for _, agg := range []string{"sum", "probor"} {
+		fc := largeModel(t, "mamdani", "prod", "probor", 300, false)
+		fc.System.Aggmethod = agg
+		models = append(models, fc)
+	}
+	models = append(models, largeModel(t, "sugeno", "prod", "sum", 300, false))
+
+	rnd := rand.New(rand.NewSource(3))
+	for _, model := range models {
+		inputs := make([][]float64, 50)
+		for k := range inputs {
+			inputs[k] = make([]float64, len(model.Inputs))
+			for i := range inputs[k] {
+				r := model.Inputs[i].Range
+				inputs[k][i] = r[0] + (r[1]-r[0])*rnd.Float64()
+			}
+		}
+
+		var golden [][]uint64
+		for run := 0; run < 10; run++ {
+			fc, err := model.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fc.SetRuleIndex(run%2 == 1)
+			outputs := make([][]uint64, len(inputs))
+			for _, k := range rnd.Perm(len(inputs)) {
+				out, err := fc.Evaluate(inputs[k])
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, v := range out {
+					outputs[k] = append(outputs[k], math.Float64bits(v))
+				}
+			}
+			if golden == nil {
+				golden = outputs
+				continue
+			}
+			for k := range outputs {
+				for o := range outputs[k] {
+					if outputs[k][o] != golden[k][o] {
+						t.Errorf("%v/%v: run %v: Evaluate(%v)[%v] = %v, first run %v", model.System.Name, model.System.Aggmethod,
+							run, inputs[k], o, math.Float64frombits(outputs[k][o]), math.Float64frombits(golden[k][o]))
+					}
+				}
+			}
+		}
+	}
+}
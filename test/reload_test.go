@@ -0,0 +1,172 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	fuzzy "fuzzy/fuzzyMod"
+	server "fuzzy/serverMod"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := server.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := server.NewRegistry()
+	if status := reg.Reload(); status.Enabled || status.LastCheck != nil {
+		t.Errorf("reload without a store: %+v", status)
+	}
+	reg.SetStore(store)
+	srv := httptest.NewServer(server.NewRouter(reg))
+	defer srv.Close()
+	write := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".json"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	method := func(name string) string {
+		h, ok := reg.Get(name)
+		if !ok {
+			return ""
+		}
+		return h.Snapshot().Info().Method
+	}
+
+	// The own writes of the registry are no changes.
+	request(t, srv, "PUT", "/v1/models/own", readModel(t, "./mamdaniModel.json"))
+	if status := reg.Reload(); status.LastCheck == nil || status.Reloads != 0 || len(status.Files) != 0 {
+		t.Errorf("reload after an upload: %+v", status)
+	}
+
+	write("ext", readModel(t, "./sugenoModel.json"))
+	if status := reg.Reload(); status.Reloads != 1 || method("ext") != "sugeno" {
+		t.Errorf("reload of a new file: %+v, method %q", status, method("ext"))
+	}
+
+	// An invalid file keeps the last valid model, and is tried
+	// once only.
+	write("ext", `{"system": {"method": "sugeno"`)
+	for k := 0; k < 2; k++ {
+		status := reg.Reload()
+		if status.Failures != 1 || len(status.Files) != 1 || status.Files[0].Action != server.ReloadFailed ||
+			status.Files[0].Error == "" || method("ext") != "sugeno" {
+			t.Errorf("reload of an invalid file: %+v, method %q", status, method("ext"))
+		}
+	}
+
+	write("ext", readModel(t, "./mamdaniModel.json"))
+	if status := reg.Reload(); status.Reloads != 2 || status.Files[0].Action != server.ReloadLoaded || method("ext") != "mamdani" {
+		t.Errorf("reload of a fixed file: %+v, method %q", status, method("ext"))
+	}
+
+	if err := os.Remove(store.Path("ext")); err != nil {
+		t.Fatal(err)
+	}
+	if status := reg.Reload(); status.Files[0].Action != server.ReloadRemoved || method("ext") != "" || method("own") != "mamdani" {
+		t.Errorf("reload of a removed file: %+v", status)
+	}
+
+	status, body := request(t, srv, "GET", "/v1/reload", "")
+	var reload server.ReloadStatus
+	if err := json.Unmarshal([]byte(body), &reload); err != nil || status != 200 || !reload.Enabled ||
+		reload.Reloads != 3 || reload.Failures != 1 || len(reload.Files) != 1 || reload.Files[0].Model != "ext" {
+		t.Errorf("GET /v1/reload: %v %v", status, body)
+	}
+
+	// Watching picks the changes up by itself.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reg.Watch(ctx, 5*time.Millisecond)
+	write("watched", readModel(t, "./sugenoModel.json"))
+	for deadline := time.Now().Add(5 * time.Second); method("watched") == ""; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("watched model not loaded")
+		}
+	}
+}
+
+// Reloads racing with the uploads and deletes of the same model
+// leave the registry agreeing with the store, and the other models
+// are served all along.
+func TestReloadConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	store, err := server.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := server.NewRegistry()
+	reg.SetStore(store)
+	model := readModel(t, "./mamdaniModel.json")
+	fc, err := fuzzy.NewFuzzyController(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Put("other", fc); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 30; k++ {
+			if k%2 == 0 {
+				reg.Put("m", fc)
+			} else {
+				reg.Delete("m")
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 30; k++ {
+			// Renamed into place, as editors and deployments do.
+			tmp := filepath.Join(dir, ".m.json.tmp")
+			if err := ioutil.WriteFile(tmp, []byte(model), 0644); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := os.Rename(tmp, store.Path("m")); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 30; k++ {
+			reg.Reload()
+		}
+	}()
+	go func() { wg.Wait(); close(done) }()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		h, ok := reg.Get("other")
+		if !ok {
+			t.Fatal("other model gone")
+		}
+		if _, err := h.Evaluate([]float64{0, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg.Reload()
+	_, served := reg.Get("m")
+	if _, err := os.Stat(store.Path("m")); served != (err == nil) {
+		t.Errorf("model served %v, file %v", served, err)
+	}
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"bytes"
+	fuzzy "fuzzy/fuzzyMod"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPublishedSchema(t *testing.T) {
+	published, err := ioutil.ReadFile("../model.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := fuzzy.JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(published, schema) {
+		t.Error("model.schema.json is out of date, run `go generate ./fuzzyMod`")
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	jsonByte, err := ioutil.ReadFile("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := string(jsonByte)
+
+	// The counts are optional.
+	lean := model
+	for _, count := range []string{`"numInputs": 2,`, `"numOutputs": 1,`, `"numRules": 4,`} {
+		lean = strings.Replace(lean, count, "", 1)
+	}
+	fc, err := fuzzy.NewFuzzyController(lean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc.System.Numinputs != 2 || fc.System.Numoutputs != 1 || fc.System.Numrules != 4 {
+		t.Errorf("counts not inferred: %+v", fc.System)
+	}
+
+	for name, bad := range map[string]string{
+		"unknown field":   strings.Replace(model, `"defuzzMethod"`, `"defuzMethod"`, 1),
+		"rule count":      strings.Replace(model, `"numRules": 4`, `"numRules": 5`, 1),
+		"method":          strings.Replace(model, `"mamdani"`, `"mamdami"`, 1),
+		"defuzz method":   strings.Replace(model, `"centroid"`, `"wtaver"`, 1),
+		"mf type":         strings.Replace(model, `"trimf"`, `"trimff"`, 1),
+		"rule label":      strings.Replace(model, `"ZO"`, `"ZZ"`, 1),
+		"trailing data":   model + "{}",
+		"malformed model": model[:len(model)/2],
+	} {
+		if _, err := fuzzy.NewFuzzyController(bad); err == nil {
+			t.Errorf("%v: expect error, got none", name)
+		}
+	}
+}
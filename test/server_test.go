@@ -0,0 +1,131 @@
+package test
+
+import (
+	"encoding/json"
+	server "fuzzy/serverMod"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Status and body of the response, also used by other goroutines
+// than the test, so failures are reported with t.Error.
+func request(t *testing.T, srv *httptest.Server, method, path, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return 0, ""
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Error(err)
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func readModel(t *testing.T, file string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRegistryRoutes(t *testing.T) {
+	srv := httptest.NewServer(server.NewRouter(server.NewRegistry()))
+	defer srv.Close()
+	mamdani := readModel(t, "./mamdaniModel.json")
+	sugeno := readModel(t, "./sugenoModel.toml")
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{"GET", "/models", "", 200, "[]\n"},
+		{"PUT", "/models/a", mamdani, 201, ""},
+		{"PUT", "/models/b", sugeno, 201, ""},
+		{"PUT", "/models/b", sugeno, 204, ""},
+		{"PUT", "/models/c", `{"system": {}}`, 400, ""},
+		{"PUT", "/models/.hidden", mamdani, 400, ""},
+		{"GET", "/models", "", 200, `["a","b"]` + "\n"},
+		{"POST", "/models/a/calculate", `{"input_x": [0, 0]}`, 200, ""},
+		{"POST", "/models/a/calculate", `{"input_x": [0]}`, 400, ""},
+		{"POST", "/models/a/calculate", `{"input_x": `, 400, ""},
+		{"POST", "/models/c/calculate", `{"input_x": [0, 0]}`, 404, ""},
+		{"DELETE", "/models/a", "", 204, ""},
+		{"DELETE", "/models/a", "", 404, ""},
+		{"GET", "/models/a", "", 404, ""},
+		{"GET", "/models", "", 200, `["b"]` + "\n"},
+	} {
+		status, body := request(t, srv, c.method, c.path, c.body)
+		if status != c.status || (c.response != "" && body != c.response) {
+			t.Errorf("%v %v: %v %q, expect %v %q", c.method, c.path, status, body, c.status, c.response)
+		}
+	}
+
+	// The stored model is served as json, the same as uploaded.
+	status, body := request(t, srv, "GET", "/models/b", "")
+	if status != 200 {
+		t.Fatalf("GET /models/b: %v %v", status, body)
+	}
+	var model struct {
+		System struct{ Method string }
+	}
+	if err := json.Unmarshal([]byte(body), &model); err != nil || model.System.Method != "sugeno" {
+		t.Errorf("GET /models/b: %v, %q", err, body)
+	}
+}
+
+// Clients of different models do not see each other's models,
+// also while the models are replaced.
+func TestRegistryConcurrent(t *testing.T) {
+	reg := server.NewRegistry()
+	srv := httptest.NewServer(server.NewRouter(reg))
+	defer srv.Close()
+	models := map[string]string{
+		"mamdani": readModel(t, "./mamdaniModel.json"),
+		"sugeno":  readModel(t, "./sugenoModel.json"),
+	}
+	want := make(map[string]string)
+	for name, model := range models {
+		request(t, srv, "PUT", "/models/"+name, model)
+		_, want[name] = request(t, srv, "POST", "/models/"+name+"/calculate", `{"input_x": [10, -5]}`)
+	}
+	if want["mamdani"] == want["sugeno"] {
+		t.Fatalf("both models give %v", want["mamdani"])
+	}
+
+	var wg sync.WaitGroup
+	for name, model := range models {
+		wg.Add(2)
+		go func(name, model string) {
+			defer wg.Done()
+			for k := 0; k < 20; k++ {
+				if status, body := request(t, srv, "PUT", "/models/"+name, model); status != 204 {
+					t.Errorf("PUT %v: %v %v", name, status, body)
+				}
+			}
+		}(name, model)
+		go func(name string) {
+			defer wg.Done()
+			for k := 0; k < 50; k++ {
+				if _, got := request(t, srv, "POST", "/models/"+name+"/calculate", `{"input_x": [10, -5]}`); got != want[name] {
+					t.Errorf("%v: %v, expect %v", name, got, want[name])
+				}
+			}
+		}(name)
+	}
+	wg.Wait()
+}
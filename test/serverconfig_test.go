@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	server "fuzzy/serverMod"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"server.yaml": "addr: 127.0.0.1:9000\nreadTimeout: 3s\nlogLevel: debug\nmodelDir: /var/models\n",
+		"server.toml": "addr = \"127.0.0.1:9000\"\nreadTimeout = \"3s\"\nlogLevel = \"debug\"\nmodelDir = \"/var/models\"\n",
+		"server.json": `{"addr": "127.0.0.1:9000", "readTimeout": "3s", "logLevel": "debug", "modelDir": "/var/models"}`,
+		"bad.yaml":    "adress: 127.0.0.1:9000\n",
+		"bad.toml":    "adress = \"127.0.0.1:9000\"\n",
+		"bad.ini":     "addr=127.0.0.1:9000\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, file := range []string{"server.yaml", "server.toml", "server.json"} {
+		env := map[string]string{
+			"FUZZY_CONFIG":        filepath.Join(dir, file),
+			"FUZZY_ADDR":          "127.0.0.1:9001",
+			"FUZZY_WRITE_TIMEOUT": "4s",
+		}
+		cfg, err := server.LoadConfig([]string{"-addr", "127.0.0.1:9002", "-read-timeout", "1m"}, func(k string) string { return env[k] })
+		if err != nil {
+			t.Fatalf("%v: %v", file, err)
+		}
+		want := server.DefaultConfig()
+		want.Addr = "127.0.0.1:9002"                    // flag over env over file
+		want.ReadTimeout = server.Duration(time.Minute) // flag over file
+		want.WriteTimeout = server.Duration(4 * time.Second)
+		want.LogLevel = "debug"
+		want.ModelDir = "/var/models"
+		if cfg != want {
+			t.Errorf("%v: %+v, expect %+v", file, cfg, want)
+		}
+	}
+
+	noEnv := func(string) string { return "" }
+	for _, args := range [][]string{
+		{"-config", filepath.Join(dir, "bad.yaml")},
+		{"-config", filepath.Join(dir, "bad.toml")},
+		{"-config", filepath.Join(dir, "bad.ini")},
+		{"-config", filepath.Join(dir, "missing.yaml")},
+		{"-read-timeout", "soon"},
+		{"-log-level", "loud"},
+		{"-write-timeout", "-1s"},
+		{"extra"},
+	} {
+		if _, err := server.LoadConfig(args, noEnv); err == nil {
+			t.Errorf("LoadConfig(%v): expect an error", args)
+		}
+	}
+	if _, err := server.LoadConfig(nil, func(k string) string {
+		if k == "FUZZY_RELOAD_INTERVAL" {
+			return "often"
+		}
+		return ""
+	}); err == nil {
+		t.Error("invalid FUZZY_RELOAD_INTERVAL: expect an error")
+	}
+}
+
+// A request in flight when the server is stopped is answered
+// before Serve returns.
+func TestServeDrains(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		// The request is not cancelled while it drains.
+		if r.Context().Err() != nil {
+			w.Write([]byte("cancelled"))
+			return
+		}
+		w.Write([]byte("done"))
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, &http.Server{Handler: handler}, ln, 5*time.Second) }()
+
+	type response struct {
+		body string
+		err  error
+	}
+	answered := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/calculate")
+		if err != nil {
+			answered <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		answered <- response{string(body), err}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned with a request in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if resp := <-answered; resp.err != nil || resp.body != "done" {
+		t.Errorf("request in flight: %q, %v", resp.body, resp.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/calculate"); err == nil {
+		t.Error("request accepted after the shutdown")
+	}
+}
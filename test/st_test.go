@@ -0,0 +1,274 @@
+package test
+
+import (
+	"fmt"
+	fuzzy "fuzzy/fuzzyMod"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Running the fuzzification of the Structured Text, which is plain
+// statements, against the membership functions of the model.
+func TestGenerateSTMembership(t *testing.T) {
+	shapes := []fuzzy.Shape{
+		fuzzy.Tri(2, 4, 6), fuzzy.Trap(1, 3, 5, 8), fuzzy.Gauss(5, 1.5),
+		fuzzy.Gauss2(3, 1, 6, 2), fuzzy.Bell(2, 3, 5), fuzzy.Sig(5, 2),
+		fuzzy.Dsig(3, 2, 7, 2), fuzzy.Psig(3, 2, 7, -2), fuzzy.Pi(1, 3, 6, 9),
+		fuzzy.S(2, 8), fuzzy.Z(2, 8),
+	}
+	b := fuzzy.NewBuilder().Name("shapes").Method("sugeno").Input("x", 0, 10)
+	for j, shape := range shapes {
+		b.Term(fmt.Sprintf("L%v", j), shape)
+	}
+	b.Output("u", 0, 10).Term("C", fuzzy.Const(1))
+	for j := range shapes {
+		b.Rule([]string{fmt.Sprintf("L%v", j)}, "C")
+	}
+	fc, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, float := range []bool{false, true} {
+		src, err := fc.GenerateST(fuzzy.GenOptions{Float: float})
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := string(src)
+		if strings.Contains(st, "EXPT(fz_x") || strings.Contains(st, "EXPT((fz_x") {
+			t.Errorf("squares by EXPT of a possibly negative base:\n%v", st)
+		}
+		start, end := strings.LastIndex(st, "END_VAR\n"), strings.Index(st, "(* Rules *)")
+		if start < 0 || end < start {
+			t.Fatalf("no fuzzification in\n%v", st)
+		}
+		body := st[start+len("END_VAR\n") : end]
+		for k := 0; k <= 200; k++ {
+			x := float64(k) / 20
+			vars, err := runST(body, map[string]float64{"x": x})
+			if err != nil {
+				t.Fatalf("%v\n%v", err, body)
+			}
+			for j, mf := range fc.Inputs[0].Mf {
+				fn, err := fuzzy.MemberFuncWrapper(mf.Type, mf.Params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, ok := vars[fmt.Sprintf("fz_m0_%v", j)]
+				if want := fn(x); !ok || math.Abs(got-want) > 1e-12 {
+					t.Errorf("%v %v at %v: %v, expect %v", mf.Type, mf.Params, x, got, want)
+				}
+			}
+		}
+	}
+}
+
+var stToken = regexp.MustCompile(`\s*([A-Za-z_][A-Za-z0-9_]*|[0-9]+\.[0-9]*(E[-+]?[0-9]+)?|:=|<=|>=|<>|[-+*/()<>=,;])`)
+
+// Interpreter of the statements of the generated Structured Text
+// without loops: assignments and IF, with the functions the
+// generator emits.
+type stRunner struct {
+	toks []string
+	pos  int
+	vars map[string]float64
+}
+
+func runST(src string, vars map[string]float64) (map[string]float64, error) {
+	src = regexp.MustCompile(`(?s)\(\*.*?\*\)`).ReplaceAllString(src, "")
+	r := &stRunner{vars: vars}
+	for rest := strings.TrimSpace(src); rest != ""; rest = strings.TrimSpace(rest) {
+		m := stToken.FindStringSubmatchIndex(rest)
+		if m == nil || m[0] != 0 {
+			return nil, fmt.Errorf("no token at %.20q", rest)
+		}
+		r.toks = append(r.toks, rest[m[2]:m[3]])
+		rest = rest[m[1]:]
+	}
+	err := func() (err error) {
+		defer func() {
+			if e := recover(); e != nil {
+				err = fmt.Errorf("%v, at token %v", e, r.pos)
+			}
+		}()
+		r.block(true)
+		if r.pos != len(r.toks) {
+			panic("unexpected " + r.peek())
+		}
+		return nil
+	}()
+	return r.vars, err
+}
+
+func (r *stRunner) peek() string {
+	if r.pos < len(r.toks) {
+		return r.toks[r.pos]
+	}
+	return ""
+}
+
+func (r *stRunner) next() string {
+	tok := r.peek()
+	r.pos++
+	return tok
+}
+
+func (r *stRunner) expect(tok string) {
+	if got := r.next(); got != tok {
+		panic(fmt.Sprintf("expect %q, got %q", tok, got))
+	}
+}
+
+// Statements up to the end of the input or of a branch, executed
+// if run.
+func (r *stRunner) block(run bool) {
+	for {
+		switch r.peek() {
+		case "", "ELSIF", "ELSE", "END_IF":
+			return
+		case "IF":
+			r.next()
+			done := false
+			for {
+				cond := r.expr() != 0
+				r.expect("THEN")
+				r.block(run && !done && cond)
+				done = done || cond
+				if r.peek() != "ELSIF" {
+					break
+				}
+				r.next()
+			}
+			if r.peek() == "ELSE" {
+				r.next()
+				r.block(run && !done)
+			}
+			r.expect("END_IF")
+			r.expect(";")
+		default:
+			name := r.next()
+			r.expect(":=")
+			v := r.expr()
+			r.expect(";")
+			if run {
+				r.vars[name] = v
+			}
+		}
+	}
+}
+
+func stBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (r *stRunner) expr() float64 {
+	v := r.and()
+	for r.peek() == "OR" {
+		r.next()
+		w := r.and()
+		v = stBool(v != 0 || w != 0)
+	}
+	return v
+}
+
+func (r *stRunner) and() float64 {
+	v := r.compare()
+	for r.peek() == "AND" {
+		r.next()
+		w := r.compare()
+		v = stBool(v != 0 && w != 0)
+	}
+	return v
+}
+
+func (r *stRunner) compare() float64 {
+	v := r.sum()
+	switch op := r.peek(); op {
+	case "<", "<=", ">", ">=", "=", "<>":
+		r.next()
+		w := r.sum()
+		return stBool(map[string]bool{
+			"<": v < w, "<=": v <= w, ">": v > w, ">=": v >= w, "=": v == w, "<>": v != w,
+		}[op])
+	}
+	return v
+}
+
+func (r *stRunner) sum() float64 {
+	v := r.product()
+	for r.peek() == "+" || r.peek() == "-" {
+		if r.next() == "+" {
+			v += r.product()
+		} else {
+			v -= r.product()
+		}
+	}
+	return v
+}
+
+func (r *stRunner) product() float64 {
+	v := r.unary()
+	for r.peek() == "*" || r.peek() == "/" {
+		if r.next() == "*" {
+			v *= r.unary()
+		} else {
+			v /= r.unary()
+		}
+	}
+	return v
+}
+
+func (r *stRunner) unary() float64 {
+	if r.peek() == "-" {
+		r.next()
+		return -r.unary()
+	}
+	return r.primary()
+}
+
+func (r *stRunner) primary() float64 {
+	tok := r.next()
+	if tok == "(" {
+		v := r.expr()
+		r.expect(")")
+		return v
+	}
+	if v, err := strconv.ParseFloat(tok, 64); err == nil {
+		return v
+	}
+	if r.peek() != "(" {
+		v, ok := r.vars[tok]
+		if !ok {
+			panic("unknown variable " + tok)
+		}
+		return v
+	}
+	r.next()
+	args := []float64{r.expr()}
+	for r.peek() == "," {
+		r.next()
+		args = append(args, r.expr())
+	}
+	r.expect(")")
+	switch {
+	case tok == "EXP" && len(args) == 1:
+		return math.Exp(args[0])
+	case tok == "ABS" && len(args) == 1:
+		return math.Abs(args[0])
+	case tok == "EXPT" && len(args) == 2:
+		if args[0] < 0 {
+			panic(fmt.Sprintf("EXPT of the negative base %v", args[0]))
+		}
+		return math.Pow(args[0], args[1])
+	case tok == "MIN" && len(args) == 2:
+		return math.Min(args[0], args[1])
+	case tok == "MAX" && len(args) == 2:
+		return math.Max(args[0], args[1])
+	}
+	panic(fmt.Sprintf("unknown function %v/%v", tok, len(args)))
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"errors"
+	fuzzy "fuzzy/fuzzyMod"
+	server "fuzzy/serverMod"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The models uploaded to a server with a store are there again
+// after a restart.
+func TestStoreRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := server.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := server.NewRegistry()
+	reg.SetStore(store)
+	srv := httptest.NewServer(server.NewRouter(reg))
+	for _, c := range []struct{ method, path, body string }{
+		{"PUT", "/v1/models/m", readModel(t, "./mamdaniModel.json")},
+		{"PUT", "/v1/models/s", readModel(t, "./sugenoModel.toml")},
+		{"PUT", "/v1/models/gone", readModel(t, "./sugenoModel.json")},
+		{"DELETE", "/v1/models/gone", ""},
+		{"POST", "/fuzzCon", readModel(t, "./sugenoModel.json")},
+	} {
+		if status, body := request(t, srv, c.method, c.path, c.body); status >= 300 {
+			t.Fatalf("%v %v: %v %v", c.method, c.path, status, body)
+		}
+	}
+	_, want := request(t, srv, "POST", "/v1/models/s/calculate", `{"input_x": [3, 4]}`)
+	srv.Close()
+
+	// Leftovers of interrupted writes and foreign files are no
+	// models, a broken model is reported.
+	for name, content := range map[string]string{
+		".m.json.tmp123": "{",
+		"notes.txt":      "not a model",
+		"broken.json":    `{"system": {}}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restarted := server.NewRegistry()
+	loaded, errs := store.LoadInto(restarted)
+	if strings.Join(loaded, ",") != "default,m,s" || len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken.json") {
+		t.Fatalf("loaded %v, errors %v", loaded, errs)
+	}
+	restarted.SetStore(store)
+	srv = httptest.NewServer(server.NewRouter(restarted))
+	defer srv.Close()
+	if _, got := request(t, srv, "POST", "/v1/models/s/calculate", `{"input_x": [3, 4]}`); got != want {
+		t.Errorf("after the restart: %v, expect %v", got, want)
+	}
+
+	// A failing store leaves the registry unchanged.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	fc, err := fuzzy.LoadFuzzyController("./mamdaniModel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var storeErr *server.StoreError
+	if _, err := restarted.Put("s", fc); !errors.As(err, &storeErr) {
+		t.Errorf("Put with the store gone: %v, expect a StoreError", err)
+	}
+	if h, _ := restarted.Get("s"); h.Snapshot().Info().Method != "sugeno" {
+		t.Error("model replaced although the store failed")
+	}
+	if status, body := request(t, srv, "PUT", "/v1/models/new", readModel(t, "./mamdaniModel.json")); status != 500 ||
+		!strings.Contains(body, server.CodeInternal) {
+		t.Errorf("PUT with the store gone: %v %v", status, body)
+	}
+	if names := restarted.Names(); strings.Join(names, ",") != "default,m,s" {
+		t.Errorf("models %v after the failures", names)
+	}
+}
+
+// Puts and deletes of the same model in parallel leave the store
+// and the registry agreeing, while the other models are served.
+func TestStoreConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	store, err := server.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := server.NewRegistry()
+	reg.SetStore(store)
+	fc, err := fuzzy.NewFuzzyController(readModel(t, "./mamdaniModel.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Put("other", fc); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for k := 0; k < 20; k++ {
+				var err error
+				if (w+k)%3 == 0 {
+					_, err = reg.Delete("m")
+				} else {
+					_, err = reg.Put("m", fc)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() { wg.Wait(); close(done) }()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		h, ok := reg.Get("other")
+		if !ok {
+			t.Fatal("other model gone")
+		}
+		if _, err := h.Evaluate([]float64{0, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, served := reg.Get("m")
+	_, err = os.Stat(store.Path("m"))
+	if served != (err == nil) {
+		t.Errorf("model served %v, file %v", served, err)
+	}
+}